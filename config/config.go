@@ -1,9 +1,11 @@
 package config
 
 import (
+	"encoding/hex"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -18,6 +20,9 @@ type Config struct {
 	Email           EmailConfig
 	FrontendBaseURL string
 	OAuth           OAuthConfig
+	Security        SecurityConfig
+	WebAuthn        WebAuthnConfig
+	Password        PasswordConfig
 }
 
 type ServerConfig struct {
@@ -31,10 +36,20 @@ type DBConfig struct {
 }
 
 type JWTConfig struct {
-	SecretKey        string
-	TokenDuration    time.Duration
-	RefreshSecretKey string
-	RefreshDuration  time.Duration
+	Format                        string
+	Algorithm                     string
+	KeyID                         string
+	SecretKey                     string
+	TokenDuration                 time.Duration
+	RefreshSecretKey              string
+	RefreshDuration               time.Duration
+	RefreshReuseGrace             time.Duration
+	PrivateKeyPath                string
+	VerificationSecretKeys        map[string]string
+	RefreshVerificationSecretKeys map[string]string
+	VerificationKeyPaths          map[string]string
+	PasetoLocalKey                string
+	PasetoRefreshLocalKey         string
 }
 
 type LogConfig struct {
@@ -59,6 +74,65 @@ type OAuthConfig struct {
 	GitHubClientID     string
 	GitHubClientSecret string
 	GitHubRedirectURL  string
+
+	GitLabClientID     string
+	GitLabClientSecret string
+	GitLabRedirectURL  string
+	GitLabBaseURL      string
+
+	BitbucketClientID     string
+	BitbucketClientSecret string
+	BitbucketRedirectURL  string
+
+	OIDCProviders []OIDCProviderConfig
+}
+
+// OIDCProviderConfig configures an OpenID Connect identity provider whose
+// endpoints are found through discovery on IssuerURL.
+type OIDCProviderConfig struct {
+	Name           string
+	IssuerURL      string
+	ClientID       string
+	ClientSecret   string
+	RedirectURL    string
+	Scopes         []string
+	FirstNameClaim string
+	LastNameClaim  string
+	TrustEmail     bool
+}
+
+type SecurityConfig struct {
+	MaxFailedLogins    int
+	FailedLoginWindow  time.Duration
+	LoginLockoutPeriod time.Duration
+	MFAIssuer          string
+	MFAEncryptionKey   string
+	MFATokenDuration   time.Duration
+	MagicLinkDuration  time.Duration
+	OTPMaxAttempts     int
+	OTPResendCooldown  time.Duration
+	DenylistStore      string
+}
+
+type PasswordConfig struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	BreachListDir string
+
+	HashAlgorithm     string
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+	BcryptCost        int
+}
+
+type WebAuthnConfig struct {
+	RPID          string
+	RPDisplayName string
+	RPOrigins     []string
 }
 
 func LoadConfig() (Config, error) {
@@ -77,11 +151,115 @@ func LoadConfig() (Config, error) {
 		return Config{}, err
 	}
 
+	refreshReuseGrace, err := parseDurationWithDefault("JWT_REFRESH_REUSE_GRACE", 30*time.Second)
+	if err != nil {
+		return Config{}, err
+	}
+
+	verificationSecretKeys, err := parseKeyList("JWT_VERIFICATION_SECRET_KEYS")
+	if err != nil {
+		return Config{}, err
+	}
+
+	refreshVerificationSecretKeys, err := parseKeyList("JWT_REFRESH_VERIFICATION_SECRET_KEYS")
+	if err != nil {
+		return Config{}, err
+	}
+
+	verificationKeyPaths, err := parseKeyList("JWT_VERIFICATION_KEY_PATHS")
+	if err != nil {
+		return Config{}, err
+	}
+
 	smtpPort, err := strconv.Atoi(getEnv("SMTP_PORT", "587"))
 	if err != nil {
 		smtpPort = 587
 	}
 
+	maxFailedLogins, err := strconv.Atoi(getEnv("LOGIN_MAX_FAILED_ATTEMPTS", "5"))
+	if err != nil {
+		maxFailedLogins = 5
+	}
+
+	failedLoginWindow, err := parseDurationWithDefault("LOGIN_FAILURE_WINDOW", 15*time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+
+	loginLockoutPeriod, err := parseDurationWithDefault("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+
+	mfaTokenDuration, err := parseDurationWithDefault("MFA_TOKEN_DURATION", 5*time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+
+	magicLinkDuration, err := parseDurationWithDefault("MAGIC_LINK_DURATION", 15*time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+
+	otpMaxAttempts, err := strconv.Atoi(getEnv("OTP_MAX_ATTEMPTS", "5"))
+	if err != nil {
+		otpMaxAttempts = 5
+	}
+
+	otpResendCooldown, err := parseDurationWithDefault("OTP_RESEND_COOLDOWN", time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+
+	// TOTP secrets are sealed with AES-256-GCM, so MFA cannot work without a
+	// 32 byte key and the server refuses to start rather than fail at enrollment.
+	mfaEncryptionKey := getEnv("MFA_ENCRYPTION_KEY", "")
+	if key, err := hex.DecodeString(mfaEncryptionKey); err != nil || len(key) != 32 {
+		return Config{}, fmt.Errorf("invalid MFA_ENCRYPTION_KEY: must be 32 hex encoded bytes")
+	}
+
+	denylistStore := getEnv("TOKEN_DENYLIST_STORE", "database")
+	if denylistStore != "database" && denylistStore != "memory" {
+		return Config{}, fmt.Errorf("unsupported TOKEN_DENYLIST_STORE: %s", denylistStore)
+	}
+
+	passwordMinLength, err := strconv.Atoi(getEnv("PASSWORD_MIN_LENGTH", "8"))
+	if err != nil {
+		passwordMinLength = 8
+	}
+
+	passwordHashAlgorithm := getEnv("PASSWORD_HASH_ALGORITHM", "argon2id")
+	if passwordHashAlgorithm != "argon2id" && passwordHashAlgorithm != "bcrypt" {
+		return Config{}, fmt.Errorf("unsupported PASSWORD_HASH_ALGORITHM: %s", passwordHashAlgorithm)
+	}
+
+	argon2Memory, err := strconv.ParseUint(getEnv("ARGON2_MEMORY", "65536"), 10, 32)
+	if err != nil {
+		argon2Memory = 65536
+	}
+
+	argon2Iterations, err := strconv.ParseUint(getEnv("ARGON2_ITERATIONS", "3"), 10, 32)
+	if err != nil {
+		argon2Iterations = 3
+	}
+
+	argon2Parallelism, err := strconv.ParseUint(getEnv("ARGON2_PARALLELISM", "2"), 10, 8)
+	if err != nil {
+		argon2Parallelism = 2
+	}
+
+	bcryptCost, err := strconv.Atoi(getEnv("BCRYPT_COST", "10"))
+	if err != nil {
+		bcryptCost = 10
+	}
+
+	oidcProviders, err := parseOIDCProviders()
+	if err != nil {
+		return Config{}, err
+	}
+
+	frontendBaseURL := getEnv("FRONTEND_BASE_URL", "http://localhost:3000")
+
 	config := Config{
 		Environment: getEnv("ENVIRONMENT", "development"),
 		Server: ServerConfig{
@@ -93,10 +271,20 @@ func LoadConfig() (Config, error) {
 			MigrationURL: getEnv("DB_MIGRATION_URL", "file://db/migration"),
 		},
 		JWT: JWTConfig{
-			SecretKey:        getEnv("JWT_SECRET_KEY", ""),
-			TokenDuration:    tokenDuration,
-			RefreshSecretKey: getEnv("JWT_REFRESH_SECRET_KEY", ""),
-			RefreshDuration:  refreshDuration,
+			Format:                        getEnv("TOKEN_FORMAT", "jwt"),
+			Algorithm:                     getEnv("JWT_ALGORITHM", "HS256"),
+			KeyID:                         getEnv("JWT_KEY_ID", ""),
+			SecretKey:                     getEnv("JWT_SECRET_KEY", ""),
+			TokenDuration:                 tokenDuration,
+			RefreshSecretKey:              getEnv("JWT_REFRESH_SECRET_KEY", ""),
+			RefreshDuration:               refreshDuration,
+			RefreshReuseGrace:             refreshReuseGrace,
+			PrivateKeyPath:                getEnv("JWT_PRIVATE_KEY_PATH", ""),
+			VerificationSecretKeys:        verificationSecretKeys,
+			RefreshVerificationSecretKeys: refreshVerificationSecretKeys,
+			VerificationKeyPaths:          verificationKeyPaths,
+			PasetoLocalKey:                getEnv("PASETO_LOCAL_KEY", ""),
+			PasetoRefreshLocalKey:         getEnv("PASETO_REFRESH_LOCAL_KEY", ""),
 		},
 		Log: LogConfig{
 			Level: getEnv("LOG_LEVEL", "info"),
@@ -111,7 +299,7 @@ func LoadConfig() (Config, error) {
 			FromName:     getEnv("EMAIL_FROM_NAME", ""),
 			TemplatesDir: getEnv("EMAIL_TEMPLATES_DIR", "./templates/emails"),
 		},
-		FrontendBaseURL: getEnv("FRONTEND_BASE_URL", "http://localhost:3000"),
+		FrontendBaseURL: frontendBaseURL,
 		OAuth: OAuthConfig{
 			GoogleClientID:     getEnv("CLIENT_ID", ""),
 			GoogleClientSecret: getEnv("CLIENT_SECRET", ""),
@@ -119,6 +307,48 @@ func LoadConfig() (Config, error) {
 			GitHubClientID:     getEnv("GitHubClientID", ""),
 			GitHubClientSecret: getEnv("GitHubClientSecret", ""),
 			GitHubRedirectURL:  getEnv("GitHubRedirectURL", ""),
+
+			GitLabClientID:     getEnv("GITLAB_CLIENT_ID", ""),
+			GitLabClientSecret: getEnv("GITLAB_CLIENT_SECRET", ""),
+			GitLabRedirectURL:  getEnv("GITLAB_REDIRECT_URL", ""),
+			GitLabBaseURL:      strings.TrimSuffix(getEnv("GITLAB_BASE_URL", "https://gitlab.com"), "/"),
+
+			BitbucketClientID:     getEnv("BITBUCKET_CLIENT_ID", ""),
+			BitbucketClientSecret: getEnv("BITBUCKET_CLIENT_SECRET", ""),
+			BitbucketRedirectURL:  getEnv("BITBUCKET_REDIRECT_URL", ""),
+
+			OIDCProviders: oidcProviders,
+		},
+		Security: SecurityConfig{
+			MaxFailedLogins:    maxFailedLogins,
+			FailedLoginWindow:  failedLoginWindow,
+			LoginLockoutPeriod: loginLockoutPeriod,
+			MFAIssuer:          getEnv("MFA_ISSUER", "CloudSprint"),
+			MFAEncryptionKey:   mfaEncryptionKey,
+			MFATokenDuration:   mfaTokenDuration,
+			MagicLinkDuration:  magicLinkDuration,
+			OTPMaxAttempts:     otpMaxAttempts,
+			OTPResendCooldown:  otpResendCooldown,
+			DenylistStore:      denylistStore,
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:          getEnv("WEBAUTHN_RP_ID", "localhost"),
+			RPDisplayName: getEnv("WEBAUTHN_RP_NAME", "CloudSprint"),
+			RPOrigins:     strings.Split(getEnv("WEBAUTHN_RP_ORIGINS", frontendBaseURL), ","),
+		},
+		Password: PasswordConfig{
+			MinLength:     passwordMinLength,
+			RequireUpper:  getEnvBool("PASSWORD_REQUIRE_UPPER", true),
+			RequireLower:  getEnvBool("PASSWORD_REQUIRE_LOWER", true),
+			RequireDigit:  getEnvBool("PASSWORD_REQUIRE_DIGIT", true),
+			RequireSymbol: getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+			BreachListDir: getEnv("PASSWORD_BREACH_LIST_DIR", ""),
+
+			HashAlgorithm:     passwordHashAlgorithm,
+			Argon2Memory:      uint32(argon2Memory),
+			Argon2Iterations:  uint32(argon2Iterations),
+			Argon2Parallelism: uint8(argon2Parallelism),
+			BcryptCost:        bcryptCost,
 		},
 	}
 
@@ -133,6 +363,14 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
 func parseDuration(key string) (time.Duration, error) {
 	durationStr := os.Getenv(key)
 
@@ -147,3 +385,73 @@ func parseDuration(key string) (time.Duration, error) {
 
 	return duration, nil
 }
+
+func parseDurationWithDefault(key string, defaultValue time.Duration) (time.Duration, error) {
+	if os.Getenv(key) == "" {
+		return defaultValue, nil
+	}
+	return parseDuration(key)
+}
+
+// parseKeyList reads a comma separated list of "kid:value" entries.
+func parseKeyList(key string) (map[string]string, error) {
+	keys := map[string]string{}
+
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, value, found := strings.Cut(entry, ":")
+		if !found || id == "" || value == "" {
+			return nil, fmt.Errorf("invalid entry in %s: expected kid:value", key)
+		}
+		keys[id] = value
+	}
+
+	return keys, nil
+}
+
+// parseOIDCProviders reads the providers named in OIDC_PROVIDERS. Each name
+// NAME is configured through OIDC_<NAME>_ISSUER_URL, OIDC_<NAME>_CLIENT_ID,
+// OIDC_<NAME>_CLIENT_SECRET, OIDC_<NAME>_REDIRECT_URL and the optional
+// OIDC_<NAME>_SCOPES, OIDC_<NAME>_FIRST_NAME_CLAIM, OIDC_<NAME>_LAST_NAME_CLAIM
+// and OIDC_<NAME>_TRUST_EMAIL.
+func parseOIDCProviders() ([]OIDCProviderConfig, error) {
+	providers := []OIDCProviderConfig{}
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		switch name {
+		case "google", "github", "gitlab", "bitbucket":
+			return nil, fmt.Errorf("invalid OIDC provider name %q: reserved for a built-in provider", name)
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		provider := OIDCProviderConfig{
+			Name:           name,
+			IssuerURL:      getEnv(prefix+"ISSUER_URL", ""),
+			ClientID:       getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret:   getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:    getEnv(prefix+"REDIRECT_URL", ""),
+			Scopes:         strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+			FirstNameClaim: getEnv(prefix+"FIRST_NAME_CLAIM", "given_name"),
+			LastNameClaim:  getEnv(prefix+"LAST_NAME_CLAIM", "family_name"),
+			TrustEmail:     getEnvBool(prefix+"TRUST_EMAIL", false),
+		}
+
+		if provider.IssuerURL == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			return nil, fmt.Errorf("OIDC provider %q requires %sISSUER_URL, %sCLIENT_ID and %sREDIRECT_URL", name, prefix, prefix, prefix)
+		}
+
+		providers = append(providers, provider)
+	}
+
+	return providers, nil
+}
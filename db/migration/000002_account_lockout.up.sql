@@ -0,0 +1 @@
+ALTER TABLE "accounts" ADD COLUMN IF NOT EXISTS "locked_until" timestamptz NULL;
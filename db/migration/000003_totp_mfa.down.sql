@@ -0,0 +1,3 @@
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "totp_enabled";
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "totp_pending_secret";
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "totp_secret";
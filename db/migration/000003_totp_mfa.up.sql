@@ -0,0 +1,3 @@
+ALTER TABLE "accounts" ADD COLUMN IF NOT EXISTS "totp_secret" varchar NULL;
+ALTER TABLE "accounts" ADD COLUMN IF NOT EXISTS "totp_pending_secret" varchar NULL;
+ALTER TABLE "accounts" ADD COLUMN IF NOT EXISTS "totp_enabled" boolean NOT NULL DEFAULT false;
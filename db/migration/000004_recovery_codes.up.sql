@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+  "id" uuid PRIMARY KEY DEFAULT (gen_random_uuid()),
+  "account_id" uuid NOT NULL,
+  "hashed_code" varchar NOT NULL,
+  "used_at" timestamptz NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "recovery_codes" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id") ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS "recovery_codes_account_id_idx" ON "recovery_codes" ("account_id");
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS "webauthn_credentials" (
+  "id" uuid PRIMARY KEY DEFAULT (gen_random_uuid()),
+  "account_id" uuid NOT NULL,
+  "credential_id" bytea UNIQUE NOT NULL,
+  "name" varchar NOT NULL,
+  "credential" jsonb NOT NULL,
+  "last_used_at" timestamptz NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE IF NOT EXISTS "webauthn_sessions" (
+  "id" uuid PRIMARY KEY,
+  "account_id" uuid NULL,
+  "ceremony" varchar NOT NULL,
+  "data" jsonb NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "webauthn_credentials" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id") ON DELETE CASCADE;
+ALTER TABLE "webauthn_sessions" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id") ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS "webauthn_credentials_account_id_idx" ON "webauthn_credentials" ("account_id");
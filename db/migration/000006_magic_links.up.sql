@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS "magic_links" (
+  "id" uuid PRIMARY KEY,
+  "email" varchar NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "used" boolean NOT NULL DEFAULT false,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX IF NOT EXISTS "magic_links_email_idx" ON "magic_links" ("email");
@@ -0,0 +1 @@
+-- Plaintext tokens cannot be restored from their digests.
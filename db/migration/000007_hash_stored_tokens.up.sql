@@ -0,0 +1,6 @@
+-- OTPs, reset tokens and refresh tokens are now stored as SHA-256 digests.
+-- Rows written before this migration hold plaintext values that can no
+-- longer be matched, so they are wiped and invalidated.
+UPDATE "email_otps" SET "otp" = '', "used" = true WHERE "used" = false;
+UPDATE "password_resets" SET "token" = '', "used" = true WHERE "used" = false;
+UPDATE "sessions" SET "refresh_token" = '', "is_blocked" = true WHERE "status" != 3;
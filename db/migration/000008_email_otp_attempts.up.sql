@@ -0,0 +1 @@
+ALTER TABLE "email_otps" ADD COLUMN IF NOT EXISTS "attempts" int NOT NULL DEFAULT 0;
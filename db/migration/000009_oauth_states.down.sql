@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "oauth_states";
\ No newline at end of file
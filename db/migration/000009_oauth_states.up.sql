@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS "oauth_states" (
+  "id" uuid PRIMARY KEY,
+  "provider" varchar NOT NULL,
+  "state_hash" varchar NOT NULL UNIQUE,
+  "binding_hash" varchar NOT NULL,
+  "code_verifier" varchar NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX IF NOT EXISTS "oauth_states_expires_at_idx" ON "oauth_states" ("expires_at");
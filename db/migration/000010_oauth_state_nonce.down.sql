@@ -0,0 +1 @@
+ALTER TABLE "oauth_states" DROP COLUMN IF EXISTS "nonce";
\ No newline at end of file
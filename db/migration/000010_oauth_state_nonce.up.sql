@@ -0,0 +1 @@
+ALTER TABLE "oauth_states" ADD COLUMN IF NOT EXISTS "nonce" varchar NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE "oauth_states" DROP COLUMN IF EXISTS "account_id";
\ No newline at end of file
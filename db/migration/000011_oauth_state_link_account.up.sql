@@ -0,0 +1 @@
+ALTER TABLE "oauth_states" ADD COLUMN IF NOT EXISTS "account_id" uuid NULL REFERENCES "accounts" ("id") ON DELETE CASCADE;
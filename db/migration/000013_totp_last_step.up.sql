@@ -0,0 +1 @@
+ALTER TABLE "accounts" ADD COLUMN IF NOT EXISTS "totp_last_step" bigint NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS "previous_refresh_token" varchar NOT NULL DEFAULT '';
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS "rotated_at" timestamptz;
@@ -15,6 +15,11 @@ WHERE email = $1 AND status != 3 LIMIT 1;
 SELECT * FROM accounts
 WHERE id = $1 AND status != 3 LIMIT 1;
 
+-- name: GetAccountByIdForUpdate :one
+SELECT * FROM accounts
+WHERE id = $1 AND status != 3 LIMIT 1
+FOR UPDATE;
+
 -- name: GetAccountByUserId :one
 SELECT * FROM accounts
 WHERE user_id = $1 AND status != 3 LIMIT 1;
@@ -59,4 +64,76 @@ UPDATE accounts
 SET email_verified = $2,
     updated_at = NOW()
 WHERE id = $1
-RETURNING *; 
\ No newline at end of file
+RETURNING *; 
+
+-- name: RecordFailedLogin :one
+UPDATE accounts
+SET
+  login_failed_attempts = CASE
+    WHEN first_failed_login_at IS NULL OR first_failed_login_at < sqlc.arg(window_start)::timestamptz THEN 1
+    ELSE login_failed_attempts + 1
+  END,
+  first_failed_login_at = CASE
+    WHEN first_failed_login_at IS NULL OR first_failed_login_at < sqlc.arg(window_start)::timestamptz THEN NOW()
+    ELSE first_failed_login_at
+  END,
+  updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: LockAccount :one
+UPDATE accounts
+SET
+  locked_until = $2,
+  login_failed_attempts = 0,
+  first_failed_login_at = NULL,
+  updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: ResetFailedLogins :exec
+UPDATE accounts
+SET
+  login_failed_attempts = 0,
+  first_failed_login_at = NULL,
+  locked_until = NULL,
+  updated_at = NOW()
+WHERE id = $1;
+
+
+-- name: SetPendingTOTPSecret :one
+UPDATE accounts
+SET
+  totp_pending_secret = $2,
+  updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: EnableTOTP :one
+UPDATE accounts
+SET
+  totp_secret = totp_pending_secret,
+  totp_pending_secret = NULL,
+  totp_enabled = true,
+  totp_last_step = sqlc.arg(totp_last_step),
+  updated_at = NOW()
+WHERE id = sqlc.arg(id) AND totp_pending_secret = sqlc.arg(totp_pending_secret)
+RETURNING *;
+
+-- name: UseTOTPStep :one
+UPDATE accounts
+SET
+  totp_last_step = sqlc.arg(totp_last_step),
+  updated_at = NOW()
+WHERE id = sqlc.arg(id) AND totp_last_step < sqlc.arg(totp_last_step)
+RETURNING *;
+
+-- name: DisableTOTP :one
+UPDATE accounts
+SET
+  totp_secret = NULL,
+  totp_pending_secret = NULL,
+  totp_enabled = false,
+  updated_at = NOW()
+WHERE id = $1
+RETURNING *;
@@ -14,6 +14,25 @@ WHERE email = $1 AND expires_at > NOW() AND used = false
 ORDER BY created_at DESC
 LIMIT 1;
 
+-- name: GetLatestEmailOTP :one
+SELECT * FROM email_otps
+WHERE email = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: RecordEmailOTPAttempt :one
+UPDATE email_otps
+SET attempts = attempts + 1
+WHERE id = sqlc.arg(id)
+  AND used = false
+  AND attempts < sqlc.arg(max_attempts)::int
+RETURNING *;
+
+-- name: InvalidateEmailOTPs :exec
+UPDATE email_otps
+SET used = true
+WHERE email = $1 AND used = false;
+
 -- name: MarkEmailOTPUsed :exec
 UPDATE email_otps
 SET used = true
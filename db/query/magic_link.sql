@@ -0,0 +1,14 @@
+-- name: CreateMagicLink :one
+INSERT INTO magic_links (
+    id,
+    email,
+    expires_at
+) VALUES (
+    $1, $2, $3
+) RETURNING *;
+
+-- name: UseMagicLink :one
+UPDATE magic_links
+SET used = true
+WHERE id = $1 AND used = false AND expires_at > NOW()
+RETURNING *;
@@ -0,0 +1,22 @@
+-- name: CreateOAuthState :one
+INSERT INTO oauth_states (
+    id,
+    provider,
+    state_hash,
+    binding_hash,
+    code_verifier,
+    nonce,
+    account_id,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING *;
+
+-- name: ConsumeOAuthState :one
+DELETE FROM oauth_states
+WHERE state_hash = $1 AND provider = $2 AND expires_at > NOW()
+RETURNING *;
+
+-- name: DeleteExpiredOAuthStates :exec
+DELETE FROM oauth_states
+WHERE expires_at < NOW();
@@ -10,11 +10,11 @@ INSERT INTO password_resets (
 
 -- name: GetPasswordResetByToken :one
 SELECT * FROM password_resets
-WHERE email = $1 AND expires_at > NOW() AND used = false
-ORDER BY created_at DESC
+WHERE token = $1 AND expires_at > NOW() AND used = false
 LIMIT 1;
 
--- name: MarkPasswordResetUsed :exec
+-- name: ConsumePasswordReset :one
 UPDATE password_resets
 SET used = true
-WHERE id = $1; 
\ No newline at end of file
+WHERE id = $1 AND expires_at > NOW() AND used = false
+RETURNING *; 
\ No newline at end of file
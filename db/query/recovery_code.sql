@@ -0,0 +1,22 @@
+-- name: CreateRecoveryCode :one
+INSERT INTO recovery_codes (
+    account_id,
+    hashed_code
+) VALUES (
+    $1, $2
+) RETURNING *;
+
+-- name: ListUnusedRecoveryCodes :many
+SELECT * FROM recovery_codes
+WHERE account_id = $1 AND used_at IS NULL
+ORDER BY created_at;
+
+-- name: UseRecoveryCode :one
+UPDATE recovery_codes
+SET used_at = NOW()
+WHERE id = $1 AND used_at IS NULL
+RETURNING *;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE account_id = $1;
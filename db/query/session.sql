@@ -16,7 +16,10 @@ WHERE id = $1 AND status != 3 LIMIT 1;
 
 -- name: ListSessions :many
 SELECT * FROM sessions
-WHERE account_id = $1 AND status != 3
+WHERE account_id = $1
+  AND status != 3
+  AND is_blocked = false
+  AND expires_at > now()
 ORDER BY created_at DESC;
 
 -- name: UpdateSessionRefreshToken :one
@@ -34,4 +37,39 @@ WHERE expires_at < now();
 -- name: DeleteSessionByAccountID :exec
 UPDATE sessions
 SET status = 3
-WHERE account_id = $1;
\ No newline at end of file
+WHERE account_id = $1;
+
+-- name: RevokeSession :one
+UPDATE sessions
+SET status = 3
+WHERE id = $1 AND account_id = $2 AND status != 3
+RETURNING *;
+
+-- name: RevokeOtherSessions :exec
+UPDATE sessions
+SET status = 3
+WHERE account_id = $1 AND id != $2 AND status != 3;
+
+-- name: BlockSession :one
+UPDATE sessions
+SET is_blocked = true
+WHERE id = $1 AND status != 3
+RETURNING *;
+
+-- name: RotateSessionRefreshToken :one
+UPDATE sessions
+SET
+  previous_refresh_token = refresh_token,
+  rotated_at = now(),
+  refresh_token = sqlc.arg(new_refresh_token),
+  expires_at = sqlc.arg(expires_at)
+WHERE id = sqlc.arg(id)
+  AND refresh_token = sqlc.arg(refresh_token)
+  AND is_blocked = false
+  AND status != 3
+RETURNING *;
+
+-- name: BlockAccountSessions :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE account_id = $1 AND status != 3;
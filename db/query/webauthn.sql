@@ -0,0 +1,51 @@
+-- name: CreateWebAuthnCredential :one
+INSERT INTO webauthn_credentials (
+    account_id,
+    credential_id,
+    name,
+    credential
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING *;
+
+-- name: ListWebAuthnCredentials :many
+SELECT * FROM webauthn_credentials
+WHERE account_id = $1
+ORDER BY created_at;
+
+-- name: GetWebAuthnCredentialByCredentialID :one
+SELECT * FROM webauthn_credentials
+WHERE credential_id = $1 LIMIT 1;
+
+-- name: UpdateWebAuthnCredentialUsage :one
+UPDATE webauthn_credentials
+SET
+  credential = $2,
+  last_used_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteWebAuthnCredential :one
+DELETE FROM webauthn_credentials
+WHERE id = $1 AND account_id = $2
+RETURNING *;
+
+-- name: CreateWebAuthnSession :one
+INSERT INTO webauthn_sessions (
+    id,
+    account_id,
+    ceremony,
+    data,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4, $5
+) RETURNING *;
+
+-- name: ConsumeWebAuthnSession :one
+DELETE FROM webauthn_sessions
+WHERE id = $1 AND ceremony = $2
+RETURNING *;
+
+-- name: DeleteExpiredWebAuthnSessions :exec
+DELETE FROM webauthn_sessions
+WHERE expires_at < NOW();
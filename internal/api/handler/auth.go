@@ -2,8 +2,9 @@ package handler
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -22,16 +23,20 @@ import (
 type AuthHandler struct {
 	store        db.Querier
 	tokenMaker   token.Maker
+	denylist     token.Denylist
 	config       config.Config
 	emailService *service.EmailService
+	totpService  *service.TOTPService
 }
 
-func NewAuthHandler(store db.Querier, tokenMaker token.Maker, config config.Config, emailService *service.EmailService) *AuthHandler {
+func NewAuthHandler(store db.Querier, tokenMaker token.Maker, denylist token.Denylist, config config.Config, emailService *service.EmailService, totpService *service.TOTPService) *AuthHandler {
 	return &AuthHandler{
 		store:        store,
 		tokenMaker:   tokenMaker,
+		denylist:     denylist,
 		config:       config,
 		emailService: emailService,
+		totpService:  totpService,
 	}
 }
 
@@ -53,7 +58,11 @@ func (h *AuthHandler) SignUp(c *fiber.Ctx) error {
 		return response.BadRequest(c, err.Error(), nil, nil)
 	}
 
-	hashedPassword, err := util.HashPassword(req.Password)
+	if violations := checkPasswordPolicy(h.config, req.Password, req.Email, req.FirstName, req.LastName); len(violations) > 0 {
+		return passwordPolicyFailed(c, violations)
+	}
+
+	hashedPassword, err := passwordHasher(h.config).Hash(req.Password)
 	if err != nil {
 		return response.BadRequest(c, "Failed to hash password", err, nil)
 	}
@@ -85,8 +94,11 @@ func (h *AuthHandler) SignUp(c *fiber.Ctx) error {
 		return response.InternalServerError(c, "Failed to create account", err, nil)
 	}
 
-	emailVerificationHandler := NewEmailVerificationHandler(h.store, h.config, h.tokenMaker, h.emailService)
-	otp := emailVerificationHandler.generateOTP()
+	otp, err := util.GenerateOTP(otpLength)
+	if err != nil {
+		log.Printf("Failed to generate OTP: %v", err)
+		return response.Created(c, nil, "User signed up successfully! Please verify your email.")
+	}
 
 	otpID := uuid.New()
 	expires := time.Now().Add(15 * time.Minute)
@@ -94,7 +106,7 @@ func (h *AuthHandler) SignUp(c *fiber.Ctx) error {
 	_, err = h.store.CreateEmailOTP(c.Context(), db.CreateEmailOTPParams{
 		ID:        otpID,
 		Email:     req.Email,
-		Otp:       otp,
+		Otp:       util.HashToken(otp),
 		ExpiresAt: expires,
 	})
 	if err != nil {
@@ -145,6 +157,10 @@ func (h *AuthHandler) SignIn(c *fiber.Ctx) error {
 		return response.InternalServerError(c, "Failed to get account", err, nil)
 	}
 
+	if account.LockedUntil.Valid && time.Now().Before(account.LockedUntil.Time) {
+		return accountLocked(c, account.LockedUntil.Time)
+	}
+
 	if !account.EmailVerified {
 		errorCode := constants.EMAIL_UNVERIFIED
 		return response.Unauthorized(c, "Email not verified", nil, &errorCode)
@@ -156,68 +172,324 @@ func (h *AuthHandler) SignIn(c *fiber.Ctx) error {
 
 	err = util.CheckPassword(req.Password, account.HashedPassword.String)
 	if err != nil {
+		if lockedUntil, locked := recordFailedLogin(c, h.store, h.emailService, h.config, account); locked {
+			return accountLocked(c, lockedUntil)
+		}
 		return response.Unauthorized(c, "Invalid email or password", err, nil)
 	}
 
-	accessToken, _, err := h.tokenMaker.CreateToken(
+	h.rehashPassword(c, account, req.Password)
+
+	return completeSignIn(c, h.store, h.tokenMaker, h.config, account)
+}
+
+// VerifyMFA completes a sign-in that requires a second factor
+// @Summary Verify MFA
+// @Description Exchange the MFA token returned by sign-in and a TOTP or recovery code for a session
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body request.VerifyMFARequest true "Verify MFA request"
+// @Success 200 {object} response.SignInResponse
+// @Router /auth/mfa/verify [post]
+func (h *AuthHandler) VerifyMFA(c *fiber.Ctx) error {
+	var req request.VerifyMFARequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request body", err, nil)
+	}
+
+	if err := req.Validate(); err != nil {
+		return response.BadRequest(c, err.Error(), nil, nil)
+	}
+
+	mfaPayload, err := h.tokenMaker.VerifyMFAToken(req.MFAToken)
+	if err != nil {
+		return response.Unauthorized(c, "Invalid or expired MFA token", err, nil)
+	}
+
+	used, err := h.denylist.Contains(c.Context(), mfaPayload.ID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to verify MFA token", err, nil)
+	}
+	if used {
+		return response.Unauthorized(c, "Invalid or expired MFA token", nil, nil)
+	}
+
+	userUUID, err := uuid.Parse(mfaPayload.UserID)
+	if err != nil {
+		return response.Unauthorized(c, "Invalid or expired MFA token", err, nil)
+	}
+
+	account, err := h.store.GetAccountByUserId(c.Context(), userUUID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return response.Unauthorized(c, "Account not found", err, nil)
+		}
+		return response.InternalServerError(c, "Failed to get account", err, nil)
+	}
+
+	if account.LockedUntil.Valid && time.Now().Before(account.LockedUntil.Time) {
+		return accountLocked(c, account.LockedUntil.Time)
+	}
+
+	if !account.TotpEnabled || !account.TotpSecret.Valid {
+		return response.Unauthorized(c, "Invalid or expired MFA token", nil, nil)
+	}
+
+	var valid bool
+	if req.RecoveryCode != "" {
+		var remaining int
+		remaining, valid, err = useRecoveryCode(c.Context(), h.store, account.ID, req.RecoveryCode)
+		if err != nil {
+			return response.InternalServerError(c, "Failed to verify recovery code", err, nil)
+		}
+		if valid {
+			h.sendRecoveryCodeUsedEmail(c, account, remaining)
+		}
+	} else {
+		valid, err = verifyTOTP(c.Context(), h.store, h.totpService, account, req.Code)
+		if err != nil {
+			return response.InternalServerError(c, "Failed to verify code", err, nil)
+		}
+	}
+
+	if !valid {
+		if lockedUntil, locked := recordFailedLogin(c, h.store, h.emailService, h.config, account); locked {
+			if err := h.denylist.Add(c.Context(), mfaPayload.ID, mfaPayload.ExpiredAt); err != nil {
+				log.Printf("Failed to revoke MFA token: %v", err)
+			}
+			return accountLocked(c, lockedUntil)
+		}
+		return response.Unauthorized(c, "Invalid verification code", nil, nil)
+	}
+
+	// The MFA token is single use. Refuse to sign in if that cannot be recorded.
+	if err := h.denylist.Add(c.Context(), mfaPayload.ID, mfaPayload.ExpiredAt); err != nil {
+		return response.InternalServerError(c, "Failed to sign in", err, nil)
+	}
+
+	resetFailedLogins(c, h.store, account)
+
+	loginResponse, err := issueSession(c, h.store, h.tokenMaker, h.config, account)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to sign in", err, nil)
+	}
+
+	return response.Success(c, loginResponse, "SignIn successful")
+}
+
+// rehashPassword upgrades a stored hash made with an outdated algorithm or
+// cost. Failures are only logged since the old hash still works.
+func (h *AuthHandler) rehashPassword(c *fiber.Ctx, account db.Account, password string) {
+	hasher := passwordHasher(h.config)
+	if !hasher.NeedsRehash(account.HashedPassword.String) {
+		return
+	}
+
+	hashedPassword, err := hasher.Hash(password)
+	if err != nil {
+		log.Printf("Failed to rehash password: %v", err)
+		return
+	}
+
+	_, err = h.store.UpdateAccountPassword(c.Context(), db.UpdateAccountPasswordParams{
+		ID:             account.ID,
+		HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Failed to store rehashed password: %v", err)
+	}
+}
+
+func (h *AuthHandler) sendRecoveryCodeUsedEmail(c *fiber.Ctx, account db.Account, remaining int) {
+	err := h.emailService.SendEmail(service.EmailData{
+		To:       account.Email,
+		Subject:  "A recovery code was used to sign in",
+		Template: "recovery_code_used.html",
+		Data: map[string]interface{}{
+			"Name":      account.Email,
+			"IPAddress": c.IP(),
+			"Remaining": remaining,
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to send recovery code used email: %v", err)
+	}
+}
+
+// completeSignIn finishes a first factor sign-in. Accounts with TOTP enabled
+// get an MFA challenge instead of a session.
+func completeSignIn(c *fiber.Ctx, store db.Querier, tokenMaker token.Maker, config config.Config, account db.Account) error {
+	challenge, err := mfaChallenge(tokenMaker, config, account)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to create MFA token", err, nil)
+	}
+	if challenge != nil {
+		return response.Success(c, challenge, "MFA verification required")
+	}
+
+	resetFailedLogins(c, store, account)
+
+	loginResponse, err := issueSession(c, store, tokenMaker, config, account)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to sign in", err, nil)
+	}
+
+	return response.Success(c, loginResponse, "SignIn successful")
+}
+
+// mfaChallenge issues the MFA token for accounts with TOTP enabled and returns
+// nil for accounts without a second factor. Every first factor sign-in must
+// go through it before a session is issued.
+func mfaChallenge(tokenMaker token.Maker, config config.Config, account db.Account) (*response.MFAChallengeResponse, error) {
+	if !account.TotpEnabled {
+		return nil, nil
+	}
+
+	mfaToken, mfaPayload, err := tokenMaker.CreateMFAToken(account.UserID, account.Email, config.Security.MFATokenDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.MFAChallengeResponse{
+		MFARequired: true,
+		MFAToken:    mfaToken,
+		ExpiresAt:   mfaPayload.ExpiredAt,
+	}, nil
+}
+
+// resetFailedLogins clears the lockout counters. It is only called once every
+// factor has been checked, so a known password cannot be used to reset the
+// TOTP attempt counter.
+func resetFailedLogins(c *fiber.Ctx, store db.Querier, account db.Account) {
+	if account.LoginFailedAttempts == 0 && !account.LockedUntil.Valid {
+		return
+	}
+
+	if err := store.ResetFailedLogins(c.Context(), account.ID); err != nil {
+		log.Printf("Failed to reset failed logins: %v", err)
+	}
+}
+
+// issueSession creates a session for an account that passed every sign-in
+// step and sets the auth cookies and header.
+func issueSession(c *fiber.Ctx, store db.Querier, tokenMaker token.Maker, config config.Config, account db.Account) (response.SignInResponse, error) {
+	sessionID := uuid.New()
+
+	accessToken, _, err := tokenMaker.CreateToken(
 		account.UserID,
 		account.Email,
-		h.config.JWT.TokenDuration,
+		sessionID,
+		config.JWT.TokenDuration,
 	)
 	if err != nil {
-		return response.InternalServerError(c, "Failed to create access token", err, nil)
+		return response.SignInResponse{}, fmt.Errorf("failed to create access token: %w", err)
 	}
 
-	refreshToken, accessPayload, err := h.tokenMaker.CreateRefreshToken(
+	refreshToken, accessPayload, err := tokenMaker.CreateRefreshToken(
 		account.UserID,
 		account.Email,
-		h.config.JWT.RefreshDuration,
+		sessionID,
+		config.JWT.RefreshDuration,
 	)
 	if err != nil {
-		return response.InternalServerError(c, "Failed to create refresh token", err, nil)
+		return response.SignInResponse{}, fmt.Errorf("failed to create refresh token: %w", err)
 	}
 
-	session, err := h.store.CreateSession(c.Context(), db.CreateSessionParams{
-		ID:           uuid.New(),
+	session, err := store.CreateSession(c.Context(), db.CreateSessionParams{
+		ID:           sessionID,
 		AccountID:    account.ID,
-		RefreshToken: refreshToken,
+		RefreshToken: util.HashToken(refreshToken),
 		UserAgent:    c.Get("User-Agent"),
 		ClientIp:     c.IP(),
-		ExpiresAt:    accessPayload.ExpiredAt.Add(h.config.JWT.TokenDuration),
+		ExpiresAt:    accessPayload.ExpiredAt.Add(config.JWT.TokenDuration),
 	})
 	if err != nil {
-		return response.InternalServerError(c, "Failed to create session", err, nil)
+		return response.SignInResponse{}, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	user, err := store.GetUserByID(c.Context(), account.UserID)
+	if err != nil {
+		return response.SignInResponse{}, fmt.Errorf("failed to get user: %w", err)
 	}
 
 	util.SetHttpOnlyCookie(c, util.SetCookieData{
 		Name:      "Authorization",
 		Token:     accessToken,
-		ExpiresAt: int(h.config.JWT.TokenDuration.Seconds()),
-		ENV:       h.config.Environment,
+		ExpiresAt: int(config.JWT.TokenDuration.Seconds()),
+		ENV:       config.Environment,
 	})
 
 	util.SetHttpOnlyCookie(c, util.SetCookieData{
 		Name:      "Refresh",
 		Token:     refreshToken,
-		ExpiresAt: int(h.config.JWT.RefreshDuration.Seconds()),
-		ENV:       h.config.Environment,
+		ExpiresAt: int(config.JWT.RefreshDuration.Seconds()),
+		ENV:       config.Environment,
 	})
 
 	c.Set("Authorization", "Bearer "+accessToken)
 
-	user, err := h.store.GetUserByID(c.Context(), account.UserID)
+	return response.NewSignInResponse(user, accessToken, refreshToken, session.ID.String()), nil
+}
+
+// recordFailedLogin counts a failed password attempt inside the configured
+// window and locks the account once the limit is reached.
+func recordFailedLogin(c *fiber.Ctx, store db.Querier, emailService *service.EmailService, config config.Config, account db.Account) (time.Time, bool) {
+	security := config.Security
+
+	account, err := store.RecordFailedLogin(c.Context(), db.RecordFailedLoginParams{
+		ID:          account.ID,
+		WindowStart: time.Now().Add(-security.FailedLoginWindow),
+	})
 	if err != nil {
-		return response.InternalServerError(c, "Failed to get user", err, nil)
+		log.Printf("Failed to record failed login: %v", err)
+		return time.Time{}, false
 	}
 
-	loginResponse := response.NewSignInResponse(user, accessToken, refreshToken, session.ID.String())
+	attempts := int(account.LoginFailedAttempts)
+	if security.MaxFailedLogins <= 0 || attempts < security.MaxFailedLogins {
+		return time.Time{}, false
+	}
 
-	return response.Success(c, loginResponse, "SignIn successful")
+	lockedUntil := time.Now().Add(security.LoginLockoutPeriod)
+	_, err = store.LockAccount(c.Context(), db.LockAccountParams{
+		ID:          account.ID,
+		LockedUntil: sql.NullTime{Time: lockedUntil, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Failed to lock account: %v", err)
+		return time.Time{}, false
+	}
+
+	err = emailService.SendEmail(service.EmailData{
+		To:       account.Email,
+		Subject:  "Your account has been locked",
+		Template: "account_locked.html",
+		Data: map[string]interface{}{
+			"Name":      account.Email,
+			"Attempts":  attempts,
+			"IPAddress": c.IP(),
+			"LockedFor": security.LoginLockoutPeriod.String(),
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to send account locked email: %v", err)
+	}
+
+	return lockedUntil, true
+}
+
+func accountLocked(c *fiber.Ctx, lockedUntil time.Time) error {
+	retryAfter := int(time.Until(lockedUntil).Seconds()) + 1
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+
+	errorCode := constants.ACCOUNT_LOCKED
+	return response.Unauthorized(c, "Account is temporarily locked due to too many failed sign-in attempts", nil, &errorCode)
 }
 
 // RefreshToken handles token refresh requests
 // @Summary Refresh token
-// @Description Refresh access token using refresh token or session ID
+// @Description Rotate the refresh token and issue a new access token. A refresh token that was already rotated blocks its session, unless it was rotated by a parallel request within the grace period; then only an access token is returned and refresh_token is empty
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -225,121 +497,65 @@ func (h *AuthHandler) SignIn(c *fiber.Ctx) error {
 // @Success 200 {object} response.RefreshTokenResponse
 // @Router /auth/refresh [post]
 func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
-	var req request.RefreshTokenRequest
-	if err := c.BodyParser(&req); err != nil {
-		return response.BadRequest(c, "Invalid request body", err, nil)
+	// The refresh middleware has already validated the request, checked the
+	// session, detected reuse and rotated the token pair. The refresh token is
+	// empty when a parallel request rotated the same token a moment earlier.
+	accessToken, _ := c.Locals("access_token").(string)
+	refreshToken, _ := c.Locals("refresh_token").(string)
+	if accessToken == "" {
+		return response.Unauthorized(c, "Invalid or expired refresh token", nil, nil)
 	}
 
-	if err := req.Validate(); err != nil {
-		return response.BadRequest(c, err.Error(), nil, nil)
+	res := response.RefreshTokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
 	}
 
-	var refreshToken string
-
-	if req.SessionID != "" {
-		sessionID, err := uuid.Parse(req.SessionID)
-		if err != nil {
-			return response.BadRequest(c, "Invalid session ID", err, nil)
-		}
-
-		session, err := h.store.GetSession(c.Context(), sessionID)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				return response.NotFound(c, "Session not found", nil, nil)
-			}
-			return response.InternalServerError(c, "Failed to get session", err, nil)
-		}
-
-		if session.IsBlocked {
-			return response.Unauthorized(c, "Session is blocked", nil, nil)
-		}
-
-		if time.Now().After(session.ExpiresAt) {
-			return response.Unauthorized(c, "Session has expired", nil, nil)
-		}
-
-		refreshToken = session.RefreshToken
-	} else {
-		if refreshToken == "" {
-			refreshToken, _ = c.Locals("refresh_token").(string)
-		}
-
-		if refreshToken == "" {
-			authHeader := c.Get("Refresh")
-			if parts := strings.Split(authHeader, " "); len(parts) == 2 && parts[0] == "Bearer" {
-				refreshToken = parts[1]
-			}
-		}
-	}
+	return response.NewSuccessResponse(c, constants.StatusOK, res, "Token refreshed successfully").Send(c)
+}
 
-	if refreshToken == "" {
-		return response.BadRequest(c, "Refresh token is required", nil, nil)
+// SignOut signs the current session out
+// @Summary Sign out
+// @Description Block the current session, revoke its tokens and clear the auth cookies
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.BaseResponse
+// @Router /auth/sign-out [post]
+func (h *AuthHandler) SignOut(c *fiber.Ctx) error {
+	sessionID, ok := c.Locals("current_session_id").(string)
+	if !ok {
+		return response.Unauthorized(c, "session not found", nil, nil)
 	}
 
-	refreshPayload, err := h.tokenMaker.VerifyRefreshToken(refreshToken)
+	sessionUUID, err := uuid.Parse(sessionID)
 	if err != nil {
-		return response.Unauthorized(c, "Invalid or expired refresh token", err, nil)
+		return response.BadRequest(c, "invalid session id", err, nil)
 	}
 
-	userUUID, err := uuid.Parse(refreshPayload.UserID)
-	if err != nil {
-		return response.BadRequest(c, "Invalid user ID format", err, nil)
+	session, err := h.store.BlockSession(c.Context(), sessionUUID)
+	if err != nil && err != sql.ErrNoRows {
+		return response.InternalServerError(c, "Failed to sign out", err, nil)
 	}
 
-	accessToken, _, err := h.tokenMaker.CreateToken(
-		userUUID,
-		refreshPayload.Email,
-		h.config.JWT.TokenDuration,
-	)
-	if err != nil {
-		return response.InternalServerError(c, "Failed to create access token", err, nil)
+	expiresAt := time.Now().Add(h.config.JWT.RefreshDuration)
+	if err == nil {
+		expiresAt = session.ExpiresAt
 	}
-
-	newRefreshToken, refreshPayload, err := h.tokenMaker.CreateRefreshToken(
-		userUUID,
-		refreshPayload.Email,
-		h.config.JWT.RefreshDuration,
-	)
-	if err != nil {
-		return response.InternalServerError(c, "Failed to create refresh token", err, nil)
+	if err := h.denylist.Add(c.Context(), sessionID, expiresAt); err != nil {
+		return response.InternalServerError(c, "Failed to sign out", err, nil)
 	}
 
-	if req.SessionID != "" {
-		sessionID, _ := uuid.Parse(req.SessionID)
-		_, err = h.store.UpdateSessionRefreshToken(c.Context(), db.UpdateSessionRefreshTokenParams{
-			ID:           sessionID,
-			RefreshToken: newRefreshToken,
-			ExpiresAt:    refreshPayload.ExpiredAt,
-		})
-		if err != nil {
-			return response.InternalServerError(c, "Failed to update session", err, nil)
+	if tokenID, ok := c.Locals("current_token_id").(string); ok {
+		if err := h.denylist.Add(c.Context(), tokenID, time.Now().Add(h.config.JWT.TokenDuration)); err != nil {
+			return response.InternalServerError(c, "Failed to sign out", err, nil)
 		}
 	}
 
-	c.Cookie(&fiber.Cookie{
-		Name:     "Authorization",
-		Value:    accessToken,
-		Path:     "/",
-		MaxAge:   int(h.config.JWT.TokenDuration.Seconds()),
-		Secure:   h.config.Environment == "production",
-		HTTPOnly: true,
-	})
-
-	c.Cookie(&fiber.Cookie{
-		Name:     "Refresh",
-		Value:    newRefreshToken,
-		Path:     "/",
-		MaxAge:   int(h.config.JWT.RefreshDuration.Seconds()),
-		Secure:   h.config.Environment == "production",
-		HTTPOnly: true,
-	})
-
-	res := response.RefreshTokenResponse{
-		AccessToken:  accessToken,
-		RefreshToken: newRefreshToken,
-	}
+	util.ClearHttpOnlyCookie(c, "Authorization", h.config.Environment)
+	util.ClearHttpOnlyCookie(c, "Refresh", h.config.Environment)
 
-	return response.NewSuccessResponse(c, constants.StatusOK, res, "Token refreshed successfully").Send(c)
+	return response.Success(c, nil, "Signed out successfully")
 }
 
 func (h *AuthHandler) Me(c *fiber.Ctx) error {
@@ -2,8 +2,7 @@ package handler
 
 import (
 	"database/sql"
-	"fmt"
-	"math/rand"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -12,6 +11,7 @@ import (
 	"cloud-sprint/config"
 	"cloud-sprint/internal/api/request"
 	"cloud-sprint/internal/api/response"
+	"cloud-sprint/internal/constants"
 	db "cloud-sprint/internal/db/sqlc"
 	"cloud-sprint/internal/service"
 	"cloud-sprint/internal/token"
@@ -34,14 +34,11 @@ func NewEmailVerificationHandler(store db.Querier, config config.Config, tokenMa
 	}
 }
 
-func (h *EmailVerificationHandler) generateOTP() string {
-	otp := rand.Intn(900000) + 100000
-	return fmt.Sprintf("%06d", otp)
-}
+const otpLength = 6
 
 // SendOTP handles sending email verification OTP
 // @Summary Send email verification OTP
-// @Description Send a one-time password to verify email
+// @Description Send a one-time password to verify the email of an unverified account
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -66,7 +63,33 @@ func (h *EmailVerificationHandler) SendOTP(c *fiber.Ctx) error {
 		return response.InternalServerError(c, "Failed to check email", err, nil)
 	}
 
-	otp := h.generateOTP()
+	if account.EmailVerified {
+		return response.BadRequest(c, "Email is already verified", nil, nil)
+	}
+
+	latestOTP, err := h.store.GetLatestEmailOTP(c.Context(), req.Email)
+	if err != nil && err != sql.ErrNoRows {
+		return response.InternalServerError(c, "Failed to check previous code", err, nil)
+	}
+
+	if err == nil {
+		resendAt := latestOTP.CreatedAt.Add(h.config.Security.OTPResendCooldown)
+		if time.Now().Before(resendAt) {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(time.Until(resendAt).Seconds())+1))
+
+			errorCode := constants.OTP_RESEND_COOLDOWN
+			return response.TooManyRequests(c, "Please wait before requesting a new verification code", &errorCode)
+		}
+	}
+
+	if err := h.store.InvalidateEmailOTPs(c.Context(), req.Email); err != nil {
+		return response.InternalServerError(c, "Failed to invalidate previous codes", err, nil)
+	}
+
+	otp, err := util.GenerateOTP(otpLength)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to generate OTP", err, nil)
+	}
 
 	otpID := uuid.New()
 	expires := time.Now().Add(15 * time.Minute)
@@ -74,7 +97,7 @@ func (h *EmailVerificationHandler) SendOTP(c *fiber.Ctx) error {
 	_, err = h.store.CreateEmailOTP(c.Context(), db.CreateEmailOTPParams{
 		ID:        otpID,
 		Email:     req.Email,
-		Otp:       otp,
+		Otp:       util.HashToken(otp),
 		ExpiresAt: expires,
 	})
 	if err != nil {
@@ -100,7 +123,7 @@ func (h *EmailVerificationHandler) SendOTP(c *fiber.Ctx) error {
 
 // VerifyOTP verifies an email OTP
 // @Summary Verify email with OTP
-// @Description Verify email address using OTP
+// @Description Verify the email address of an unverified account using OTP and sign in. Accounts with TOTP enabled get an MFA challenge instead of a session
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -117,87 +140,67 @@ func (h *EmailVerificationHandler) VerifyOTP(c *fiber.Ctx) error {
 		return response.BadRequest(c, err.Error(), nil, nil)
 	}
 
-	otpRecord, err := h.store.GetEmailOTPByCode(c.Context(), req.Email)
+	// The code signs the user in, so it is only accepted while the email is
+	// still unverified. Verified accounts sign in through SignIn, which
+	// enforces the lockout and the second factor.
+	account, err := h.store.GetAccountByEmail(c.Context(), req.Email)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return response.BadRequest(c, "Invalid or expired verification code", nil, nil)
 		}
-		return response.InternalServerError(c, "Failed to verify code", err, nil)
+		return response.InternalServerError(c, "Failed to get account", err, nil)
 	}
 
-	if otpRecord.Otp != req.OTP {
-		return response.BadRequest(c, "Invalid or expired verification code", nil, nil)
+	if account.EmailVerified {
+		return response.BadRequest(c, "Email is already verified", nil, nil)
 	}
 
-	account, err := h.store.GetAccountByEmail(c.Context(), req.Email)
-	if err != nil {
-		return response.InternalServerError(c, "Failed to get account", err, nil)
+	if account.LockedUntil.Valid && time.Now().Before(account.LockedUntil.Time) {
+		return accountLocked(c, account.LockedUntil.Time)
 	}
 
-	err = h.store.MarkEmailOTPUsed(c.Context(), otpRecord.ID)
-	if err != nil {
-		return response.InternalServerError(c, "Failed to mark code as used", err, nil)
-	}
-
-	updatedAccount, err := h.store.UpdateAccountEmailVerified(c.Context(), account.ID)
+	otpRecord, err := h.store.GetEmailOTPByCode(c.Context(), req.Email)
 	if err != nil {
-		return response.InternalServerError(c, "Failed to update verification status", err, nil)
+		if err == sql.ErrNoRows {
+			return response.BadRequest(c, "Invalid or expired verification code", nil, nil)
+		}
+		return response.InternalServerError(c, "Failed to verify code", err, nil)
 	}
 
-	accessToken, _, err := h.tokenMaker.CreateToken(
-		updatedAccount.UserID,
-		updatedAccount.Email,
-		h.config.JWT.TokenDuration,
-	)
+	// The attempt is counted before the code is compared so concurrent guesses
+	// cannot exceed the limit.
+	otpRecord, err = h.store.RecordEmailOTPAttempt(c.Context(), db.RecordEmailOTPAttemptParams{
+		ID:          otpRecord.ID,
+		MaxAttempts: int32(h.config.Security.OTPMaxAttempts),
+	})
 	if err != nil {
-		return response.InternalServerError(c, "Failed to create access token", err, nil)
+		if err == sql.ErrNoRows {
+			return h.otpAttemptsExceeded(c)
+		}
+		return response.InternalServerError(c, "Failed to verify code", err, nil)
 	}
 
-	refreshToken, accessPayload, err := h.tokenMaker.CreateRefreshToken(
-		updatedAccount.UserID,
-		updatedAccount.Email,
-		h.config.JWT.RefreshDuration,
-	)
-	if err != nil {
-		return response.InternalServerError(c, "Failed to create refresh token", err, nil)
+	if !util.CheckTokenHash(req.OTP, otpRecord.Otp) {
+		if int(otpRecord.Attempts) >= h.config.Security.OTPMaxAttempts {
+			if err := h.store.MarkEmailOTPUsed(c.Context(), otpRecord.ID); err != nil {
+				return response.InternalServerError(c, "Failed to invalidate code", err, nil)
+			}
+			return h.otpAttemptsExceeded(c)
+		}
+		return response.BadRequest(c, "Invalid or expired verification code", nil, nil)
 	}
 
-	session, err := h.store.CreateSession(c.Context(), db.CreateSessionParams{
-		ID:           uuid.New(),
-		AccountID:    updatedAccount.ID,
-		RefreshToken: refreshToken,
-		UserAgent:    c.Get("User-Agent"),
-		ClientIp:     c.IP(),
-		ExpiresAt:    accessPayload.ExpiredAt.Add(h.config.JWT.TokenDuration),
-	})
+	err = h.store.MarkEmailOTPUsed(c.Context(), otpRecord.ID)
 	if err != nil {
-		return response.InternalServerError(c, "Failed to create session", err, nil)
+		return response.InternalServerError(c, "Failed to mark code as used", err, nil)
 	}
 
-	util.SetHttpOnlyCookie(c, util.SetCookieData{
-		Name:      "Authorization",
-		Token:     accessToken,
-		ExpiresAt: int(h.config.JWT.TokenDuration.Seconds()),
-		ENV:       h.config.Environment,
-	})
-
-	util.SetHttpOnlyCookie(c, util.SetCookieData{
-		Name:      "Refresh",
-		Token:     refreshToken,
-		ExpiresAt: int(h.config.JWT.RefreshDuration.Seconds()),
-		ENV:       h.config.Environment,
-	})
-
-	c.Set("Authorization", "Bearer "+accessToken)
-
-	user, err := h.store.GetUserByID(c.Context(), updatedAccount.UserID)
+	updatedAccount, err := h.store.UpdateAccountEmailVerified(c.Context(), account.ID)
 	if err != nil {
-		return response.InternalServerError(c, "Failed to get user", err, nil)
+		return response.InternalServerError(c, "Failed to update verification status", err, nil)
 	}
 
-	loginResponse := response.NewSignInResponse(user, accessToken, refreshToken, session.ID.String())
-
-	return response.Success(c, loginResponse, "SignIn successful")
+	return completeSignIn(c, h.store, h.tokenMaker, h.config, updatedAccount)
 }
 
 // CheckVerificationStatus checks if a user's email is verified
@@ -229,3 +232,8 @@ func (h *EmailVerificationHandler) CheckVerificationStatus(c *fiber.Ctx) error {
 		EmailVerified: emailVerified,
 	}, "Verification status retrieved")
 }
+
+func (h *EmailVerificationHandler) otpAttemptsExceeded(c *fiber.Ctx) error {
+	errorCode := constants.OTP_ATTEMPTS_EXCEEDED
+	return response.BadRequest(c, "Too many incorrect attempts, please request a new verification code", nil, &errorCode)
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"cloud-sprint/config"
+	"cloud-sprint/internal/api/response"
+	database "cloud-sprint/internal/db"
+	db "cloud-sprint/internal/db/sqlc"
+	"cloud-sprint/internal/service"
+)
+
+var (
+	errIdentityNotLinked = errors.New("identity not linked")
+	errLastSignInMethod  = errors.New("identity is the last sign-in method")
+)
+
+type IdentityHandler struct {
+	store     database.Store
+	config    config.Config
+	providers *service.OAuthProviderRegistry
+	oauthFlow *oauthFlow
+}
+
+func NewIdentityHandler(store database.Store, config config.Config, providers *service.OAuthProviderRegistry) *IdentityHandler {
+	return &IdentityHandler{
+		store:     store,
+		config:    config,
+		providers: providers,
+		oauthFlow: newOAuthFlow(store, config),
+	}
+}
+
+// ListIdentities lists the OAuth identities linked to the authenticated user
+// @Summary List linked identities
+// @Description List the OAuth and OIDC provider identities linked to the authenticated user
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} response.IdentityResponse
+// @Router /auth/identities [get]
+func (h *IdentityHandler) ListIdentities(c *fiber.Ctx) error {
+	account, err := currentAccount(c, h.store)
+	if err != nil {
+		return response.Unauthorized(c, "Account not found", err, nil)
+	}
+
+	oauthAccounts, err := h.store.GetOAuthAccountsByAccountID(c.Context(), account.ID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to list identities", err, nil)
+	}
+
+	return response.Success(c, response.NewIdentitiesResponse(oauthAccounts), "Identities retrieved successfully")
+}
+
+// LinkIdentity starts linking a provider identity to the authenticated user
+// @Summary Link identity
+// @Description Start an OAuth flow that links the provider identity to the authenticated user. Open the returned URL in the browser; the callback finishes the link
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Security BearerAuth
+// @Success 200 {object} response.IdentityLinkResponse
+// @Router /auth/identities/{provider} [post]
+func (h *IdentityHandler) LinkIdentity(c *fiber.Ctx) error {
+	provider, ok := h.providers.Get(c.Params("provider"))
+	if !ok {
+		return response.NotFound(c, "Unknown OAuth provider", nil, nil)
+	}
+
+	account, err := currentAccount(c, h.store)
+	if err != nil {
+		return response.Unauthorized(c, "Account not found", err, nil)
+	}
+
+	linkAccountID := uuid.NullUUID{UUID: account.ID, Valid: true}
+	url, err := h.oauthFlow.authCodeURL(c, provider.Name(), provider.OAuthConfig(), linkAccountID, provider.AuthCodeOptions()...)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to start linking", err, nil)
+	}
+
+	return response.Success(c, response.IdentityLinkResponse{URL: url}, "Continue at the provider to link your account")
+}
+
+// UnlinkIdentity removes a provider identity from the authenticated user
+// @Summary Unlink identity
+// @Description Unlink a provider identity. Refused when it is the only way left to sign in
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Security BearerAuth
+// @Success 200 {object} response.BaseResponse
+// @Router /auth/identities/{provider} [delete]
+func (h *IdentityHandler) UnlinkIdentity(c *fiber.Ctx) error {
+	providerName := c.Params("provider")
+
+	account, err := currentAccount(c, h.store)
+	if err != nil {
+		return response.Unauthorized(c, "Account not found", err, nil)
+	}
+
+	// The account row is locked so concurrent unlinks cannot remove the
+	// last two sign-in methods at once.
+	err = h.store.ExecTx(c.Context(), func(q db.Querier) error {
+		lockedAccount, err := q.GetAccountByIdForUpdate(c.Context(), account.ID)
+		if err != nil {
+			return err
+		}
+
+		oauthAccounts, err := q.GetOAuthAccountsByAccountID(c.Context(), account.ID)
+		if err != nil {
+			return err
+		}
+
+		methods, err := signInMethodCount(c.Context(), q, lockedAccount)
+		if err != nil {
+			return err
+		}
+
+		var identity *db.OauthAccount
+		for i := range oauthAccounts {
+			if oauthAccounts[i].Provider == providerName {
+				identity = &oauthAccounts[i]
+				break
+			}
+		}
+		if identity == nil {
+			return errIdentityNotLinked
+		}
+
+		if methods <= 1 {
+			return errLastSignInMethod
+		}
+
+		return q.DeleteOAuthAccount(c.Context(), identity.ID)
+	})
+	if err != nil {
+		switch err {
+		case errIdentityNotLinked:
+			return response.NotFound(c, "Identity not linked", nil, nil)
+		case errLastSignInMethod:
+			return response.BadRequest(c, "Set a password or add a passkey before unlinking your only sign-in method", nil, nil)
+		}
+		return response.InternalServerError(c, "Failed to unlink identity", err, nil)
+	}
+
+	return response.Success(c, nil, "Identity unlinked successfully")
+}
+
+// signInMethodCount counts the ways the account can sign in on its own: a
+// password, each passkey and each linked identity. Email codes are not counted
+// since they only work until the email is verified.
+func signInMethodCount(ctx context.Context, q db.Querier, account db.Account) (int, error) {
+	passkeys, err := q.ListWebAuthnCredentials(ctx, account.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	oauthAccounts, err := q.GetOAuthAccountsByAccountID(ctx, account.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	methods := len(passkeys) + len(oauthAccounts)
+	if account.HashedPassword.Valid {
+		methods++
+	}
+
+	return methods, nil
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"cloud-sprint/internal/token"
+)
+
+type JWKSHandler struct {
+	tokenMaker token.Maker
+}
+
+func NewJWKSHandler(tokenMaker token.Maker) *JWKSHandler {
+	return &JWKSHandler{
+		tokenMaker: tokenMaker,
+	}
+}
+
+// GetJWKS returns the public keys used to sign access tokens
+// @Summary JSON Web Key Set
+// @Description Public keys other services use to verify CloudSprint access tokens offline. Refresh, MFA and magic-link tokens are signed with a secret key and cannot be verified with this set
+// @Tags auth
+// @Produce json
+// @Success 200 {object} token.JWKS
+// @Router /.well-known/jwks.json [get]
+func (h *JWKSHandler) GetJWKS(c *fiber.Ctx) error {
+	keySet := token.JWKS{Keys: []token.JWK{}}
+	if provider, ok := h.tokenMaker.(token.KeySetProvider); ok {
+		keySet = provider.JWKS()
+	}
+
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(keySet)
+}
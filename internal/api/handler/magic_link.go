@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"cloud-sprint/config"
+	"cloud-sprint/internal/api/request"
+	"cloud-sprint/internal/api/response"
+	db "cloud-sprint/internal/db/sqlc"
+	"cloud-sprint/internal/service"
+	"cloud-sprint/internal/token"
+)
+
+type MagicLinkHandler struct {
+	store        db.Querier
+	tokenMaker   token.Maker
+	config       config.Config
+	emailService *service.EmailService
+}
+
+func NewMagicLinkHandler(store db.Querier, tokenMaker token.Maker, config config.Config, emailService *service.EmailService) *MagicLinkHandler {
+	return &MagicLinkHandler{
+		store:        store,
+		tokenMaker:   tokenMaker,
+		config:       config,
+		emailService: emailService,
+	}
+}
+
+// RequestMagicLink emails a one-time sign-in link
+// @Summary Request magic link
+// @Description Send a single-use, short-lived sign-in link to the email address
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body request.MagicLinkRequest true "Magic link request"
+// @Success 200 {object} response.BaseResponse
+// @Router /auth/magic-link [post]
+func (h *MagicLinkHandler) RequestMagicLink(c *fiber.Ctx) error {
+	var req request.MagicLinkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request body", err, nil)
+	}
+
+	if err := req.Validate(); err != nil {
+		return response.BadRequest(c, err.Error(), nil, nil)
+	}
+
+	account, err := h.store.GetAccountByEmail(c.Context(), req.Email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return response.Success(c, nil, "If your email is registered, you will receive a sign-in link")
+		}
+		return response.InternalServerError(c, "Failed to check email", err, nil)
+	}
+
+	magicLinkToken, payload, err := h.tokenMaker.CreateMagicLinkToken(account.UserID, account.Email, h.config.Security.MagicLinkDuration)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to create magic link", err, nil)
+	}
+
+	linkID, err := uuid.Parse(payload.ID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to create magic link", err, nil)
+	}
+
+	_, err = h.store.CreateMagicLink(c.Context(), db.CreateMagicLinkParams{
+		ID:        linkID,
+		Email:     account.Email,
+		ExpiresAt: payload.ExpiredAt,
+	})
+	if err != nil {
+		return response.InternalServerError(c, "Failed to create magic link", err, nil)
+	}
+
+	magicLinkURL := fmt.Sprintf("%s/magic-link?token=%s", h.config.FrontendBaseURL, url.QueryEscape(magicLinkToken))
+
+	err = h.emailService.SendEmail(service.EmailData{
+		To:       account.Email,
+		Subject:  "Your sign-in link",
+		Template: "magic_link.html",
+		Data: map[string]interface{}{
+			"Name":         account.Email,
+			"MagicLinkURL": magicLinkURL,
+			"ExpiresIn":    h.config.Security.MagicLinkDuration.String(),
+		},
+	})
+	if err != nil {
+		return response.InternalServerError(c, "Failed to send sign-in email", err, nil)
+	}
+
+	return response.Success(c, nil, "If your email is registered, you will receive a sign-in link")
+}
+
+// ConsumeMagicLink signs the user in with a magic link token
+// @Summary Consume magic link
+// @Description Exchange the token of a magic link for a session. The link can only be used once
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body request.ConsumeMagicLinkRequest true "Consume magic link request"
+// @Success 200 {object} response.SignInResponse
+// @Router /auth/magic-link/consume [post]
+func (h *MagicLinkHandler) ConsumeMagicLink(c *fiber.Ctx) error {
+	var req request.ConsumeMagicLinkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request body", err, nil)
+	}
+
+	if err := req.Validate(); err != nil {
+		return response.BadRequest(c, err.Error(), nil, nil)
+	}
+
+	payload, err := h.tokenMaker.VerifyMagicLinkToken(req.Token)
+	if err != nil {
+		return response.Unauthorized(c, "Invalid or expired magic link", err, nil)
+	}
+
+	linkID, err := uuid.Parse(payload.ID)
+	if err != nil {
+		return response.Unauthorized(c, "Invalid or expired magic link", err, nil)
+	}
+
+	if _, err := h.store.UseMagicLink(c.Context(), linkID); err != nil {
+		if err == sql.ErrNoRows {
+			return response.Unauthorized(c, "Invalid or expired magic link", nil, nil)
+		}
+		return response.InternalServerError(c, "Failed to verify magic link", err, nil)
+	}
+
+	userUUID, err := uuid.Parse(payload.UserID)
+	if err != nil {
+		return response.Unauthorized(c, "Invalid or expired magic link", err, nil)
+	}
+
+	account, err := h.store.GetAccountByUserId(c.Context(), userUUID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return response.Unauthorized(c, "Account not found", err, nil)
+		}
+		return response.InternalServerError(c, "Failed to get account", err, nil)
+	}
+
+	if account.Email != payload.Email {
+		return response.Unauthorized(c, "Invalid or expired magic link", nil, nil)
+	}
+
+	if account.LockedUntil.Valid && time.Now().Before(account.LockedUntil.Time) {
+		return accountLocked(c, account.LockedUntil.Time)
+	}
+
+	// Following the link proves control of the inbox.
+	if !account.EmailVerified {
+		account, err = h.store.UpdateAccountEmailVerificationStatus(c.Context(), db.UpdateAccountEmailVerificationStatusParams{
+			ID:            account.ID,
+			EmailVerified: true,
+		})
+		if err != nil {
+			return response.InternalServerError(c, "Failed to verify email", err, nil)
+		}
+	}
+
+	return completeSignIn(c, h.store, h.tokenMaker, h.config, account)
+}
@@ -0,0 +1,365 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"cloud-sprint/config"
+	"cloud-sprint/internal/api/request"
+	"cloud-sprint/internal/api/response"
+	database "cloud-sprint/internal/db"
+	db "cloud-sprint/internal/db/sqlc"
+	"cloud-sprint/internal/service"
+	"cloud-sprint/pkg/util"
+)
+
+const (
+	recoveryCodeCount    = 10
+	recoveryCodeLength   = 10
+	recoveryCodeAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+)
+
+type MFAHandler struct {
+	store       database.Store
+	config      config.Config
+	totpService *service.TOTPService
+}
+
+func NewMFAHandler(store database.Store, config config.Config, totpService *service.TOTPService) *MFAHandler {
+	return &MFAHandler{
+		store:       store,
+		config:      config,
+		totpService: totpService,
+	}
+}
+
+// EnrollTOTP starts a TOTP enrollment for the authenticated user
+// @Summary Enroll TOTP
+// @Description Generate a new TOTP secret. Re-enrolling while TOTP is enabled requires a code from the current authenticator
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Param request body request.EnrollTOTPRequest false "Enroll TOTP request"
+// @Security BearerAuth
+// @Success 200 {object} response.TOTPEnrollmentResponse
+// @Router /auth/mfa/totp/enroll [post]
+func (h *MFAHandler) EnrollTOTP(c *fiber.Ctx) error {
+	var req request.EnrollTOTPRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return response.BadRequest(c, "Invalid request body", err, nil)
+		}
+	}
+
+	account, err := currentAccount(c, h.store)
+	if err != nil {
+		return response.Unauthorized(c, "Account not found", err, nil)
+	}
+
+	if account.TotpEnabled {
+		valid, err := verifyTOTP(c.Context(), h.store, h.totpService, account, req.Code)
+		if err != nil {
+			return response.InternalServerError(c, "Failed to verify code", err, nil)
+		}
+		if !valid {
+			return response.Unauthorized(c, "A valid code from your current authenticator is required to re-enroll", nil, nil)
+		}
+	}
+
+	enrollment, encryptedSecret, err := h.totpService.Enroll(account.Email)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to generate TOTP secret", err, nil)
+	}
+
+	_, err = h.store.SetPendingTOTPSecret(c.Context(), db.SetPendingTOTPSecretParams{
+		ID:                account.ID,
+		TotpPendingSecret: sql.NullString{String: encryptedSecret, Valid: true},
+	})
+	if err != nil {
+		return response.InternalServerError(c, "Failed to save TOTP secret", err, nil)
+	}
+
+	return response.Success(c, response.TOTPEnrollmentResponse{
+		Secret:     enrollment.Secret,
+		OTPAuthURL: enrollment.OTPAuthURL,
+		QRCode:     enrollment.QRCode,
+	}, "Scan the QR code and confirm with a code from your authenticator")
+}
+
+// ConfirmTOTP enables TOTP once the user proves the authenticator is set up
+// @Summary Confirm TOTP
+// @Description Confirm a pending TOTP enrollment with a code, enable it and return a new set of recovery codes
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Param request body request.ConfirmTOTPRequest true "Confirm TOTP request"
+// @Security BearerAuth
+// @Success 200 {object} response.RecoveryCodesResponse
+// @Router /auth/mfa/totp/confirm [post]
+func (h *MFAHandler) ConfirmTOTP(c *fiber.Ctx) error {
+	var req request.ConfirmTOTPRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request body", err, nil)
+	}
+
+	if err := req.Validate(); err != nil {
+		return response.BadRequest(c, err.Error(), nil, nil)
+	}
+
+	account, err := currentAccount(c, h.store)
+	if err != nil {
+		return response.Unauthorized(c, "Account not found", err, nil)
+	}
+
+	if !account.TotpPendingSecret.Valid {
+		return response.BadRequest(c, "No TOTP enrollment in progress", nil, nil)
+	}
+
+	// totp_last_step belongs to the current secret, so a new secret starts
+	// from zero and a code is never refused for a step the old one used.
+	step, valid, err := h.totpService.Validate(req.Code, account.TotpPendingSecret.String, 0)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to verify code", err, nil)
+	}
+	if !valid {
+		return response.BadRequest(c, "Invalid verification code", nil, nil)
+	}
+
+	// The confirming code's step replaces the old secret's step so it cannot
+	// be replayed at sign-in. Matching on the pending secret makes a second
+	// confirmation, or one racing a new enrollment, fail.
+	_, err = h.store.EnableTOTP(c.Context(), db.EnableTOTPParams{
+		ID:                account.ID,
+		TotpPendingSecret: account.TotpPendingSecret,
+		TotpLastStep:      step,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return response.BadRequest(c, "No TOTP enrollment in progress", nil, nil)
+		}
+		return response.InternalServerError(c, "Failed to enable TOTP", err, nil)
+	}
+
+	recoveryCodes, err := replaceRecoveryCodes(c.Context(), h.store, account.ID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to generate recovery codes", err, nil)
+	}
+
+	return response.Success(c, response.RecoveryCodesResponse{RecoveryCodes: recoveryCodes}, "Two-factor authentication enabled. Store your recovery codes somewhere safe")
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the authenticated user
+// @Summary Regenerate recovery codes
+// @Description Invalidate every existing recovery code and return a new set
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Param request body request.RegenerateRecoveryCodesRequest true "Regenerate recovery codes request"
+// @Security BearerAuth
+// @Success 200 {object} response.RecoveryCodesResponse
+// @Router /auth/mfa/recovery-codes [post]
+func (h *MFAHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	var req request.RegenerateRecoveryCodesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request body", err, nil)
+	}
+
+	if err := req.Validate(); err != nil {
+		return response.BadRequest(c, err.Error(), nil, nil)
+	}
+
+	account, err := currentAccount(c, h.store)
+	if err != nil {
+		return response.Unauthorized(c, "Account not found", err, nil)
+	}
+
+	if !account.TotpEnabled {
+		return response.BadRequest(c, "Two-factor authentication is not enabled", nil, nil)
+	}
+
+	valid, err := verifyTOTP(c.Context(), h.store, h.totpService, account, req.Code)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to verify code", err, nil)
+	}
+	if !valid {
+		return response.Unauthorized(c, "Invalid verification code", nil, nil)
+	}
+
+	recoveryCodes, err := replaceRecoveryCodes(c.Context(), h.store, account.ID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to generate recovery codes", err, nil)
+	}
+
+	return response.Success(c, response.RecoveryCodesResponse{RecoveryCodes: recoveryCodes}, "Recovery codes regenerated successfully")
+}
+
+// DisableTOTP turns TOTP off for the authenticated user
+// @Summary Disable TOTP
+// @Description Disable TOTP with the account password and a current code
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Param request body request.DisableTOTPRequest true "Disable TOTP request"
+// @Security BearerAuth
+// @Success 200 {object} response.BaseResponse
+// @Router /auth/mfa/totp/disable [post]
+func (h *MFAHandler) DisableTOTP(c *fiber.Ctx) error {
+	var req request.DisableTOTPRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request body", err, nil)
+	}
+
+	if err := req.Validate(); err != nil {
+		return response.BadRequest(c, err.Error(), nil, nil)
+	}
+
+	account, err := currentAccount(c, h.store)
+	if err != nil {
+		return response.Unauthorized(c, "Account not found", err, nil)
+	}
+
+	if !account.TotpEnabled {
+		return response.BadRequest(c, "Two-factor authentication is not enabled", nil, nil)
+	}
+
+	if account.HashedPassword.Valid {
+		if err := util.CheckPassword(req.Password, account.HashedPassword.String); err != nil {
+			return response.Unauthorized(c, "Invalid password", nil, nil)
+		}
+	}
+
+	valid, err := verifyTOTP(c.Context(), h.store, h.totpService, account, req.Code)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to verify code", err, nil)
+	}
+	if !valid {
+		return response.Unauthorized(c, "Invalid verification code", nil, nil)
+	}
+
+	if _, err := h.store.DisableTOTP(c.Context(), account.ID); err != nil {
+		return response.InternalServerError(c, "Failed to disable TOTP", err, nil)
+	}
+
+	if err := h.store.DeleteRecoveryCodes(c.Context(), account.ID); err != nil {
+		return response.InternalServerError(c, "Failed to delete recovery codes", err, nil)
+	}
+
+	return response.Success(c, nil, "Two-factor authentication disabled")
+}
+
+// verifyTOTP checks a code against the active secret and records its time
+// step, so each code is accepted at most once even inside the skew window.
+func verifyTOTP(ctx context.Context, store db.Querier, totpService *service.TOTPService, account db.Account, code string) (bool, error) {
+	step, valid, err := totpService.Validate(code, account.TotpSecret.String, account.TotpLastStep)
+	if err != nil || !valid {
+		return false, err
+	}
+
+	// The update only matches while the stored step is older, so two
+	// concurrent requests with the same code cannot both succeed.
+	_, err = store.UseTOTPStep(ctx, db.UseTOTPStepParams{
+		ID:           account.ID,
+		TotpLastStep: step,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// replaceRecoveryCodes invalidates the current recovery codes and stores the
+// bcrypt hashes of a new set. The plain codes are only ever returned here.
+// The swap runs in one transaction so a failure never leaves the account
+// with part of a set; hashing happens before it to keep the transaction short.
+func replaceRecoveryCodes(ctx context.Context, store database.Store, accountID uuid.UUID) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashedCodes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		hashedCode, err := util.HashPassword(normalizeRecoveryCode(code))
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, code)
+		hashedCodes = append(hashedCodes, hashedCode)
+	}
+
+	err := store.ExecTx(ctx, func(q db.Querier) error {
+		if err := q.DeleteRecoveryCodes(ctx, accountID); err != nil {
+			return err
+		}
+
+		for _, hashedCode := range hashedCodes {
+			_, err := q.CreateRecoveryCode(ctx, db.CreateRecoveryCodeParams{
+				AccountID:  accountID,
+				HashedCode: hashedCode,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// useRecoveryCode marks the matching unused code as used and returns how many
+// codes are left.
+func useRecoveryCode(ctx context.Context, store db.Querier, accountID uuid.UUID, code string) (int, bool, error) {
+	recoveryCodes, err := store.ListUnusedRecoveryCodes(ctx, accountID)
+	if err != nil {
+		return 0, false, err
+	}
+
+	code = normalizeRecoveryCode(code)
+	for _, recoveryCode := range recoveryCodes {
+		if util.CheckPassword(code, recoveryCode.HashedCode) != nil {
+			continue
+		}
+
+		if _, err := store.UseRecoveryCode(ctx, recoveryCode.ID); err != nil {
+			if err == sql.ErrNoRows {
+				return 0, false, nil
+			}
+			return 0, false, err
+		}
+
+		return len(recoveryCodes) - 1, true, nil
+	}
+
+	return 0, false, nil
+}
+
+func newRecoveryCode() (string, error) {
+	code, err := util.GenerateSecretString(recoveryCodeAlphabet, recoveryCodeLength)
+	if err != nil {
+		return "", err
+	}
+
+	return code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
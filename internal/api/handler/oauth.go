@@ -0,0 +1,289 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+
+	"cloud-sprint/config"
+	"cloud-sprint/internal/api/response"
+	"cloud-sprint/internal/constants"
+	db "cloud-sprint/internal/db/sqlc"
+	"cloud-sprint/internal/service"
+	"cloud-sprint/internal/token"
+)
+
+var (
+	errOAuthEmailUnverified   = errors.New("provider did not return a verified email")
+	errOAuthIdentityNotLinked = errors.New("an account with this email exists but the identity is not linked")
+	errOAuthIdentityInUse     = errors.New("identity is linked to another account")
+	errOAuthProviderLinked    = errors.New("account already has an identity of this provider")
+)
+
+type OAuthHandler struct {
+	store      db.Querier
+	tokenMaker token.Maker
+	config     config.Config
+	providers  *service.OAuthProviderRegistry
+	oauthFlow  *oauthFlow
+}
+
+func NewOAuthHandler(store db.Querier, tokenMaker token.Maker, config config.Config, providers *service.OAuthProviderRegistry) *OAuthHandler {
+	return &OAuthHandler{
+		store:      store,
+		tokenMaker: tokenMaker,
+		config:     config,
+		providers:  providers,
+		oauthFlow:  newOAuthFlow(store, config),
+	}
+}
+
+// Authorize starts the OAuth flow of a provider
+// @Summary Initiate OAuth sign-in
+// @Description Redirect to the identity provider for authentication
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Provider name: google, github, gitlab, bitbucket or a configured OIDC provider"
+// @Router /auth/{provider}/auth [get]
+func (h *OAuthHandler) Authorize(c *fiber.Ctx) error {
+	provider, ok := h.providers.Get(c.Params("provider"))
+	if !ok {
+		return response.NotFound(c, "Unknown OAuth provider", nil, nil)
+	}
+
+	url, err := h.oauthFlow.authCodeURL(c, provider.Name(), provider.OAuthConfig(), uuid.NullUUID{}, provider.AuthCodeOptions()...)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to start sign-in", err, nil)
+	}
+
+	return c.Redirect(url)
+}
+
+// Callback processes the callback of a provider
+// @Summary OAuth callback
+// @Description Exchange the authorization code, then sign the user in or finish linking the identity, and redirect to the frontend. Accounts with TOTP enabled are redirected with an MFA token to complete at /auth/mfa/verify
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Provider name: google, github, gitlab, bitbucket or a configured OIDC provider"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State for CSRF protection"
+// @Router /auth/{provider}/callback [get]
+func (h *OAuthHandler) Callback(c *fiber.Ctx) error {
+	provider, ok := h.providers.Get(c.Params("provider"))
+	if !ok {
+		return response.NotFound(c, "Unknown OAuth provider", nil, nil)
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		return response.BadRequest(c, "Missing authorization code", nil, nil)
+	}
+
+	callback, err := h.oauthFlow.verify(c, provider.Name())
+	if err != nil {
+		if err == errInvalidOAuthState {
+			return response.BadRequest(c, "Invalid or expired state parameter", nil, nil)
+		}
+		return response.InternalServerError(c, "Failed to verify state", err, nil)
+	}
+
+	oauthToken, err := provider.Exchange(c.Context(), code, callback.verifier)
+	if err != nil {
+		log.Printf("Token exchange error for %s: %v", provider.Name(), err)
+		return response.InternalServerError(c, "Failed to exchange token", err, nil)
+	}
+
+	userInfo, err := provider.GetUserInfo(c.Context(), oauthToken, callback.nonce)
+	if err != nil {
+		log.Printf("User info error for %s: %v", provider.Name(), err)
+		return response.InternalServerError(c, "Failed to get user info", err, nil)
+	}
+
+	if callback.linkAccountID.Valid {
+		err := linkOAuthIdentity(c.Context(), h.store, callback.linkAccountID.UUID, provider.Name(), userInfo, oauthToken)
+		if err != nil {
+			return oauthLinkFailed(c, provider.Name(), err)
+		}
+
+		return c.Redirect(fmt.Sprintf("%s/auth/callback?provider=%s&linked=true", h.config.FrontendBaseURL, provider.Name()))
+	}
+
+	account, err := h.findOrCreateAccount(c.Context(), provider.Name(), userInfo, oauthToken)
+	if err != nil {
+		switch err {
+		case errOAuthEmailUnverified:
+			return response.BadRequest(c, "The provider account does not have a verified email", nil, nil)
+		case errOAuthIdentityNotLinked:
+			errorCode := constants.IDENTITY_NOT_LINKED
+			return response.BadRequest(c, "An account with this email already exists. Sign in and link "+provider.Name()+" from your account settings", nil, &errorCode)
+		}
+		return response.InternalServerError(c, "Failed to sign in", err, nil)
+	}
+
+	if account.LockedUntil.Valid && time.Now().Before(account.LockedUntil.Time) {
+		return accountLocked(c, account.LockedUntil.Time)
+	}
+
+	challenge, err := mfaChallenge(h.tokenMaker, h.config, account)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to create MFA token", err, nil)
+	}
+	if challenge != nil {
+		return c.Redirect(fmt.Sprintf("%s/auth/callback?provider=%s&mfa_required=true&mfa_token=%s",
+			h.config.FrontendBaseURL, provider.Name(), url.QueryEscape(challenge.MFAToken)))
+	}
+
+	resetFailedLogins(c, h.store, account)
+
+	signIn, err := issueSession(c, h.store, h.tokenMaker, h.config, account)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to create session", err, nil)
+	}
+
+	redirectURL := fmt.Sprintf("%s/auth/callback?access_token=%s&refresh_token=%s&session_id=%s&provider=%s",
+		h.config.FrontendBaseURL, signIn.AccessToken, signIn.RefreshToken, signIn.SessionID, provider.Name())
+
+	return c.Redirect(redirectURL)
+}
+
+// findOrCreateAccount resolves the account linked to the provider identity and
+// creates a new account for unknown identities. An identity is never attached
+// to an existing account by matching email; the owner has to link it.
+func (h *OAuthHandler) findOrCreateAccount(ctx context.Context, provider string, userInfo *service.OAuthUserInfo, oauthToken *oauth2.Token) (db.Account, error) {
+	oauthAccount, err := h.store.GetOAuthAccountByProviderAndProviderUserID(ctx, db.GetOAuthAccountByProviderAndProviderUserIDParams{
+		Provider:       provider,
+		ProviderUserID: userInfo.ProviderUserID,
+	})
+	if err == nil {
+		saveOAuthTokens(ctx, h.store, oauthAccount.ID, oauthToken)
+		return h.store.GetAccountById(ctx, oauthAccount.AccountID)
+	}
+	if err != sql.ErrNoRows {
+		return db.Account{}, err
+	}
+
+	if userInfo.Email == "" || !userInfo.EmailVerified {
+		return db.Account{}, errOAuthEmailUnverified
+	}
+
+	_, err = h.store.GetAccountByEmail(ctx, userInfo.Email)
+	if err == nil {
+		return db.Account{}, errOAuthIdentityNotLinked
+	}
+	if err != sql.ErrNoRows {
+		return db.Account{}, err
+	}
+
+	account, err := h.createAccount(ctx, userInfo)
+	if err != nil {
+		return db.Account{}, err
+	}
+
+	if err := linkOAuthIdentity(ctx, h.store, account.ID, provider, userInfo, oauthToken); err != nil {
+		return db.Account{}, err
+	}
+
+	return account, nil
+}
+
+// createAccount creates an account without a password. The user can set one
+// later through the password reset flow.
+func (h *OAuthHandler) createAccount(ctx context.Context, userInfo *service.OAuthUserInfo) (db.Account, error) {
+	firstName, lastName := userInfo.AccountNames()
+	user, err := h.store.CreateUser(ctx, db.CreateUserParams{
+		Email:     userInfo.Email,
+		FirstName: firstName,
+		LastName:  lastName,
+	})
+	if err != nil {
+		return db.Account{}, err
+	}
+
+	account, err := h.store.CreateAccount(ctx, db.CreateAccountParams{
+		UserID: user.ID,
+		Email:  userInfo.Email,
+	})
+	if err != nil {
+		return db.Account{}, err
+	}
+
+	return h.store.UpdateAccountEmailVerificationStatus(ctx, db.UpdateAccountEmailVerificationStatusParams{
+		ID:            account.ID,
+		EmailVerified: true,
+	})
+}
+
+// linkOAuthIdentity attaches a provider identity to an account. Linking an
+// identity the account already has only refreshes the stored tokens.
+func linkOAuthIdentity(ctx context.Context, store db.Querier, accountID uuid.UUID, provider string, userInfo *service.OAuthUserInfo, oauthToken *oauth2.Token) error {
+	oauthAccount, err := store.GetOAuthAccountByProviderAndProviderUserID(ctx, db.GetOAuthAccountByProviderAndProviderUserIDParams{
+		Provider:       provider,
+		ProviderUserID: userInfo.ProviderUserID,
+	})
+	if err == nil {
+		if oauthAccount.AccountID != accountID {
+			return errOAuthIdentityInUse
+		}
+		saveOAuthTokens(ctx, store, oauthAccount.ID, oauthToken)
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	_, err = store.GetOAuthAccountByAccountIDAndProvider(ctx, db.GetOAuthAccountByAccountIDAndProviderParams{
+		AccountID: accountID,
+		Provider:  provider,
+	})
+	if err == nil {
+		return errOAuthProviderLinked
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	now := time.Now()
+	_, err = store.CreateOAuthAccount(ctx, db.CreateOAuthAccountParams{
+		ID:             uuid.New(),
+		AccountID:      accountID,
+		Provider:       provider,
+		ProviderUserID: userInfo.ProviderUserID,
+		AccessToken:    sql.NullString{String: oauthToken.AccessToken, Valid: true},
+		RefreshToken:   sql.NullString{String: oauthToken.RefreshToken, Valid: oauthToken.RefreshToken != ""},
+		ExpiresAt:      sql.NullTime{Time: oauthToken.Expiry, Valid: !oauthToken.Expiry.IsZero()},
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	})
+	return err
+}
+
+func saveOAuthTokens(ctx context.Context, store db.Querier, oauthAccountID uuid.UUID, oauthToken *oauth2.Token) {
+	_, err := store.UpdateOAuthAccount(ctx, db.UpdateOAuthAccountParams{
+		ID:           oauthAccountID,
+		AccessToken:  sql.NullString{String: oauthToken.AccessToken, Valid: true},
+		RefreshToken: sql.NullString{String: oauthToken.RefreshToken, Valid: oauthToken.RefreshToken != ""},
+		ExpiresAt:    sql.NullTime{Time: oauthToken.Expiry, Valid: !oauthToken.Expiry.IsZero()},
+		UpdatedAt:    time.Now(),
+	})
+	if err != nil {
+		log.Printf("Failed to update OAuth account: %v", err)
+	}
+}
+
+func oauthLinkFailed(c *fiber.Ctx, provider string, err error) error {
+	switch err {
+	case errOAuthIdentityInUse:
+		return response.BadRequest(c, "This "+provider+" account is already linked to another user", nil, nil)
+	case errOAuthProviderLinked:
+		return response.BadRequest(c, "A different "+provider+" account is already linked", nil, nil)
+	}
+	return response.InternalServerError(c, "Failed to link "+provider+" account", err, nil)
+}
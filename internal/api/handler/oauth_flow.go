@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+
+	"cloud-sprint/config"
+	db "cloud-sprint/internal/db/sqlc"
+	"cloud-sprint/pkg/util"
+)
+
+const (
+	oauthStateDuration = 10 * time.Minute
+	oauthBindingCookie = "oauth_binding"
+)
+
+var errInvalidOAuthState = errors.New("invalid or expired OAuth state")
+
+// oauthFlow issues and checks the state of an authorization code flow. Each
+// state is stored hashed, bound to a random cookie on the browser that started
+// the flow and carries a PKCE (S256) code verifier and an OpenID Connect nonce.
+// A state can be consumed once.
+type oauthFlow struct {
+	store  db.Querier
+	config config.Config
+}
+
+// oauthCallback is what a verified callback needs to finish the flow.
+// linkAccountID is set when the flow links an identity to a signed in account
+// instead of signing in.
+type oauthCallback struct {
+	verifier      oauth2.AuthCodeOption
+	nonce         string
+	linkAccountID uuid.NullUUID
+}
+
+func newOAuthFlow(store db.Querier, config config.Config) *oauthFlow {
+	return &oauthFlow{
+		store:  store,
+		config: config,
+	}
+}
+
+// authCodeURL starts a flow for provider and returns the URL to redirect to.
+// Pass a valid linkAccountID to link the identity to that account.
+func (f *oauthFlow) authCodeURL(c *fiber.Ctx, provider string, oauthConfig *oauth2.Config, linkAccountID uuid.NullUUID, opts ...oauth2.AuthCodeOption) (string, error) {
+	state, err := util.GenerateState()
+	if err != nil {
+		return "", err
+	}
+
+	binding, err := util.GenerateToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	nonce, err := util.GenerateToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	verifier := oauth2.GenerateVerifier()
+
+	if err := f.store.DeleteExpiredOAuthStates(c.Context()); err != nil {
+		log.Printf("Failed to delete expired OAuth states: %v", err)
+	}
+
+	_, err = f.store.CreateOAuthState(c.Context(), db.CreateOAuthStateParams{
+		ID:           uuid.New(),
+		Provider:     provider,
+		StateHash:    util.HashToken(state),
+		BindingHash:  util.HashToken(binding),
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		AccountID:    linkAccountID,
+		ExpiresAt:    time.Now().Add(oauthStateDuration),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// The provider redirects back cross-site, which a SameSite=Strict cookie
+	// would not survive.
+	c.Cookie(&fiber.Cookie{
+		Name:     oauthBindingCookie,
+		Value:    binding,
+		Path:     "/",
+		MaxAge:   int(oauthStateDuration.Seconds()),
+		HTTPOnly: true,
+		Secure:   f.config.Environment == "production",
+		SameSite: "Lax",
+	})
+
+	opts = append(opts, oauth2.S256ChallengeOption(verifier), oauth2.SetAuthURLParam("nonce", nonce))
+	return oauthConfig.AuthCodeURL(state, opts...), nil
+}
+
+// verify consumes the state of a callback. Mismatched, replayed and expired
+// states return errInvalidOAuthState.
+func (f *oauthFlow) verify(c *fiber.Ctx, provider string) (oauthCallback, error) {
+	state := c.Query("state")
+	binding := c.Cookies(oauthBindingCookie)
+
+	util.ClearHttpOnlyCookie(c, oauthBindingCookie, f.config.Environment)
+
+	if state == "" || binding == "" {
+		return oauthCallback{}, errInvalidOAuthState
+	}
+
+	oauthState, err := f.store.ConsumeOAuthState(c.Context(), db.ConsumeOAuthStateParams{
+		StateHash: util.HashToken(state),
+		Provider:  provider,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return oauthCallback{}, errInvalidOAuthState
+		}
+		return oauthCallback{}, err
+	}
+
+	if !util.CheckTokenHash(binding, oauthState.BindingHash) {
+		return oauthCallback{}, errInvalidOAuthState
+	}
+
+	return oauthCallback{
+		verifier:      oauth2.VerifierOption(oauthState.CodeVerifier),
+		nonce:         oauthState.Nonce,
+		linkAccountID: oauthState.AccountID,
+	}, nil
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+
+	"cloud-sprint/config"
+	db "cloud-sprint/internal/db/sqlc"
+	"cloud-sprint/internal/service"
+)
+
+// fakeOAuthStore answers the queries of a first OAuth sign-in and enforces the
+// CHECK constraints of the users table the way Postgres would.
+type fakeOAuthStore struct {
+	db.Querier
+	users []db.User
+}
+
+func (s *fakeOAuthStore) GetOAuthAccountByProviderAndProviderUserID(ctx context.Context, arg db.GetOAuthAccountByProviderAndProviderUserIDParams) (db.OauthAccount, error) {
+	return db.OauthAccount{}, sql.ErrNoRows
+}
+
+func (s *fakeOAuthStore) GetOAuthAccountByAccountIDAndProvider(ctx context.Context, arg db.GetOAuthAccountByAccountIDAndProviderParams) (db.OauthAccount, error) {
+	return db.OauthAccount{}, sql.ErrNoRows
+}
+
+func (s *fakeOAuthStore) GetAccountByEmail(ctx context.Context, email string) (db.Account, error) {
+	return db.Account{}, sql.ErrNoRows
+}
+
+func (s *fakeOAuthStore) CreateUser(ctx context.Context, arg db.CreateUserParams) (db.User, error) {
+	for column, value := range map[string]string{"first_name": arg.FirstName, "last_name": arg.LastName} {
+		if length := utf8.RuneCountInString(value); length <= 1 || length >= 256 {
+			return db.User{}, fmt.Errorf("pq: new row for relation \"users\" violates check constraint on %s", column)
+		}
+	}
+
+	user := db.User{
+		ID:        uuid.New(),
+		Email:     arg.Email,
+		FirstName: arg.FirstName,
+		LastName:  arg.LastName,
+	}
+	s.users = append(s.users, user)
+	return user, nil
+}
+
+func (s *fakeOAuthStore) CreateAccount(ctx context.Context, arg db.CreateAccountParams) (db.Account, error) {
+	return db.Account{ID: uuid.New(), UserID: arg.UserID, Email: arg.Email}, nil
+}
+
+func (s *fakeOAuthStore) UpdateAccountEmailVerificationStatus(ctx context.Context, arg db.UpdateAccountEmailVerificationStatusParams) (db.Account, error) {
+	return db.Account{ID: arg.ID, EmailVerified: arg.EmailVerified}, nil
+}
+
+func (s *fakeOAuthStore) CreateOAuthAccount(ctx context.Context, arg db.CreateOAuthAccountParams) (db.OauthAccount, error) {
+	return db.OauthAccount{ID: arg.ID, AccountID: arg.AccountID, Provider: arg.Provider, ProviderUserID: arg.ProviderUserID}, nil
+}
+
+// Providers return whatever names the identity carries; the account created
+// from them must still satisfy the users table.
+func TestOAuthFindOrCreateAccountNames(t *testing.T) {
+	tests := []struct {
+		name          string
+		firstName     string
+		lastName      string
+		wantFirstName string
+		wantLastName  string
+	}{
+		{name: "full name", firstName: "Jane", lastName: "Doe", wantFirstName: "Jane", wantLastName: "Doe"},
+		{name: "no last name", firstName: "jdoe", lastName: "", wantFirstName: "jdoe", wantLastName: "Unknown"},
+		{name: "no names", firstName: "", lastName: "", wantFirstName: "User", wantLastName: "Unknown"},
+		{name: "single letters", firstName: "j", lastName: "D", wantFirstName: "User", wantLastName: "Unknown"},
+		{name: "single rune", firstName: "李", lastName: "王", wantFirstName: "User", wantLastName: "Unknown"},
+		{name: "blank", firstName: "   ", lastName: " D ", wantFirstName: "User", wantLastName: "Unknown"},
+		{name: "too long", firstName: strings.Repeat("a", 300), lastName: "Doe", wantFirstName: strings.Repeat("a", 255), wantLastName: "Doe"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeOAuthStore{}
+			h := NewOAuthHandler(store, nil, config.Config{}, service.NewOAuthProviderRegistry())
+
+			userInfo := &service.OAuthUserInfo{
+				ProviderUserID: "user-123",
+				Email:          "jane.doe@example.com",
+				EmailVerified:  true,
+				FirstName:      tt.firstName,
+				LastName:       tt.lastName,
+			}
+
+			account, err := h.findOrCreateAccount(context.Background(), "mock", userInfo, &oauth2.Token{AccessToken: "access-token"})
+			if err != nil {
+				t.Fatalf("findOrCreateAccount: %v", err)
+			}
+			if !account.EmailVerified {
+				t.Fatal("account created from a verified email is not verified")
+			}
+
+			if len(store.users) != 1 {
+				t.Fatalf("created %d users, want 1", len(store.users))
+			}
+			user := store.users[0]
+			if user.FirstName != tt.wantFirstName || user.LastName != tt.wantLastName {
+				t.Fatalf("name = %q %q, want %q %q", user.FirstName, user.LastName, tt.wantFirstName, tt.wantLastName)
+			}
+		})
+	}
+}
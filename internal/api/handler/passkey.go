@@ -0,0 +1,458 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"cloud-sprint/config"
+	"cloud-sprint/internal/api/request"
+	"cloud-sprint/internal/api/response"
+	database "cloud-sprint/internal/db"
+	db "cloud-sprint/internal/db/sqlc"
+	"cloud-sprint/internal/service"
+	"cloud-sprint/internal/token"
+)
+
+const (
+	passkeyCeremonyRegistration = "registration"
+	passkeyCeremonyLogin        = "login"
+	passkeyCeremonyTimeout      = 5 * time.Minute
+	defaultPasskeyName          = "Passkey"
+)
+
+var (
+	errPasskeyCeremonyExpired = errors.New("passkey ceremony not found or expired")
+	errPasskeyNotFound        = errors.New("passkey not found")
+)
+
+// passkeyCeremony is the state kept between the begin and finish steps of a
+// WebAuthn ceremony.
+type passkeyCeremony struct {
+	Session webauthn.SessionData `json:"session"`
+	Name    string               `json:"name,omitempty"`
+}
+
+type PasskeyHandler struct {
+	store           database.Store
+	tokenMaker      token.Maker
+	config          config.Config
+	webAuthnService *service.WebAuthnService
+}
+
+func NewPasskeyHandler(store database.Store, tokenMaker token.Maker, config config.Config, webAuthnService *service.WebAuthnService) *PasskeyHandler {
+	return &PasskeyHandler{
+		store:           store,
+		tokenMaker:      tokenMaker,
+		config:          config,
+		webAuthnService: webAuthnService,
+	}
+}
+
+// BeginRegistration starts registering a passkey for the authenticated user
+// @Summary Begin passkey registration
+// @Description Return the WebAuthn creation options and the ceremony ID to finish the registration with
+// @Tags passkeys
+// @Accept json
+// @Produce json
+// @Param request body request.BeginPasskeyRegistrationRequest false "Begin passkey registration request"
+// @Security BearerAuth
+// @Success 200 {object} response.PasskeyCeremonyResponse
+// @Router /auth/passkeys/register/begin [post]
+func (h *PasskeyHandler) BeginRegistration(c *fiber.Ctx) error {
+	var req request.BeginPasskeyRegistrationRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return response.BadRequest(c, "Invalid request body", err, nil)
+		}
+	}
+
+	if err := req.Validate(); err != nil {
+		return response.BadRequest(c, err.Error(), nil, nil)
+	}
+
+	account, err := currentAccount(c, h.store)
+	if err != nil {
+		return response.Unauthorized(c, "Account not found", err, nil)
+	}
+
+	user, err := h.webAuthnUser(c.Context(), account)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to load passkeys", err, nil)
+	}
+
+	creation, sessionData, err := h.webAuthnService.BeginRegistration(user)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to begin passkey registration", err, nil)
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = defaultPasskeyName
+	}
+
+	ceremonyID, err := h.saveCeremony(c.Context(), uuid.NullUUID{UUID: account.ID, Valid: true}, passkeyCeremonyRegistration, passkeyCeremony{
+		Session: *sessionData,
+		Name:    name,
+	})
+	if err != nil {
+		return response.InternalServerError(c, "Failed to begin passkey registration", err, nil)
+	}
+
+	return response.Success(c, response.PasskeyCeremonyResponse{
+		CeremonyID: ceremonyID.String(),
+		Options:    creation,
+	}, "Passkey registration started")
+}
+
+// FinishRegistration stores the passkey created by the authenticator
+// @Summary Finish passkey registration
+// @Description Verify the attestation returned by the authenticator and store the new passkey
+// @Tags passkeys
+// @Accept json
+// @Produce json
+// @Param request body request.FinishPasskeyCeremonyRequest true "Finish passkey registration request"
+// @Security BearerAuth
+// @Success 201 {object} response.PasskeyResponse
+// @Router /auth/passkeys/register/finish [post]
+func (h *PasskeyHandler) FinishRegistration(c *fiber.Ctx) error {
+	var req request.FinishPasskeyCeremonyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request body", err, nil)
+	}
+
+	if err := req.Validate(); err != nil {
+		return response.BadRequest(c, err.Error(), nil, nil)
+	}
+
+	account, err := currentAccount(c, h.store)
+	if err != nil {
+		return response.Unauthorized(c, "Account not found", err, nil)
+	}
+
+	ceremony, err := h.consumeCeremony(c.Context(), req.CeremonyID, passkeyCeremonyRegistration, uuid.NullUUID{UUID: account.ID, Valid: true})
+	if err != nil {
+		if err == errPasskeyCeremonyExpired {
+			return response.BadRequest(c, "Passkey registration has expired, please try again", nil, nil)
+		}
+		return response.InternalServerError(c, "Failed to finish passkey registration", err, nil)
+	}
+
+	user, err := h.webAuthnUser(c.Context(), account)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to load passkeys", err, nil)
+	}
+
+	credential, err := h.webAuthnService.FinishRegistration(user, ceremony.Session, req.Credential)
+	if err != nil {
+		return response.BadRequest(c, "Passkey registration failed", err, nil)
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to save passkey", err, nil)
+	}
+
+	passkey, err := h.store.CreateWebAuthnCredential(c.Context(), db.CreateWebAuthnCredentialParams{
+		AccountID:    account.ID,
+		CredentialID: credential.ID,
+		Name:         ceremony.Name,
+		Credential:   data,
+	})
+	if err != nil {
+		return response.InternalServerError(c, "Failed to save passkey", err, nil)
+	}
+
+	return response.Created(c, response.NewPasskeyResponse(passkey), "Passkey registered successfully")
+}
+
+// BeginLogin starts a passwordless sign-in with a passkey
+// @Summary Begin passkey sign-in
+// @Description Return the WebAuthn request options and the ceremony ID to finish the sign-in with
+// @Tags passkeys
+// @Produce json
+// @Success 200 {object} response.PasskeyCeremonyResponse
+// @Router /auth/passkeys/login/begin [post]
+func (h *PasskeyHandler) BeginLogin(c *fiber.Ctx) error {
+	assertion, sessionData, err := h.webAuthnService.BeginLogin()
+	if err != nil {
+		return response.InternalServerError(c, "Failed to begin passkey sign-in", err, nil)
+	}
+
+	ceremonyID, err := h.saveCeremony(c.Context(), uuid.NullUUID{}, passkeyCeremonyLogin, passkeyCeremony{
+		Session: *sessionData,
+	})
+	if err != nil {
+		return response.InternalServerError(c, "Failed to begin passkey sign-in", err, nil)
+	}
+
+	return response.Success(c, response.PasskeyCeremonyResponse{
+		CeremonyID: ceremonyID.String(),
+		Options:    assertion,
+	}, "Passkey sign-in started")
+}
+
+// FinishLogin signs the user in with a passkey assertion
+// @Summary Finish passkey sign-in
+// @Description Verify the assertion returned by the authenticator and create a session
+// @Tags passkeys
+// @Accept json
+// @Produce json
+// @Param request body request.FinishPasskeyCeremonyRequest true "Finish passkey sign-in request"
+// @Success 200 {object} response.SignInResponse
+// @Router /auth/passkeys/login/finish [post]
+func (h *PasskeyHandler) FinishLogin(c *fiber.Ctx) error {
+	var req request.FinishPasskeyCeremonyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request body", err, nil)
+	}
+
+	if err := req.Validate(); err != nil {
+		return response.BadRequest(c, err.Error(), nil, nil)
+	}
+
+	ceremony, err := h.consumeCeremony(c.Context(), req.CeremonyID, passkeyCeremonyLogin, uuid.NullUUID{})
+	if err != nil {
+		if err == errPasskeyCeremonyExpired {
+			return response.Unauthorized(c, "Passkey sign-in has expired, please try again", nil, nil)
+		}
+		return response.InternalServerError(c, "Failed to finish passkey sign-in", err, nil)
+	}
+
+	var account db.Account
+	findUser := func(rawID, userHandle []byte) (webauthn.User, error) {
+		accountID, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+
+		account, err = h.store.GetAccountById(c.Context(), accountID)
+		if err != nil {
+			return nil, err
+		}
+
+		return h.webAuthnUser(c.Context(), account)
+	}
+
+	_, credential, err := h.webAuthnService.FinishLogin(findUser, ceremony.Session, req.Credential)
+	if err != nil {
+		return response.Unauthorized(c, "Passkey verification failed", err, nil)
+	}
+
+	if credential.Authenticator.CloneWarning {
+		log.Printf("security: possible cloned passkey for account %s from %s", account.ID, c.IP())
+		return response.Unauthorized(c, "Passkey verification failed", nil, nil)
+	}
+
+	if account.LockedUntil.Valid && time.Now().Before(account.LockedUntil.Time) {
+		return accountLocked(c, account.LockedUntil.Time)
+	}
+
+	if err := h.updateCredentialUsage(c.Context(), credential); err != nil {
+		log.Printf("Failed to update passkey usage: %v", err)
+	}
+
+	resetFailedLogins(c, h.store, account)
+
+	loginResponse, err := issueSession(c, h.store, h.tokenMaker, h.config, account)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to sign in", err, nil)
+	}
+
+	return response.Success(c, loginResponse, "SignIn successful")
+}
+
+// ListPasskeys returns the passkeys of the authenticated user
+// @Summary List passkeys
+// @Description List the passkeys registered by the authenticated user
+// @Tags passkeys
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} response.PasskeyResponse
+// @Router /auth/passkeys [get]
+func (h *PasskeyHandler) ListPasskeys(c *fiber.Ctx) error {
+	account, err := currentAccount(c, h.store)
+	if err != nil {
+		return response.Unauthorized(c, "Account not found", err, nil)
+	}
+
+	passkeys, err := h.store.ListWebAuthnCredentials(c.Context(), account.ID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to list passkeys", err, nil)
+	}
+
+	return response.Success(c, response.NewPasskeysResponse(passkeys), "Passkeys retrieved successfully")
+}
+
+// DeletePasskey removes one of the authenticated user's passkeys
+// @Summary Delete passkey
+// @Description Delete a passkey of the authenticated user by ID. Refused when it is the only way left to sign in
+// @Tags passkeys
+// @Produce json
+// @Param id path string true "Passkey ID"
+// @Security BearerAuth
+// @Success 200 {object} response.BaseResponse
+// @Router /auth/passkeys/{id} [delete]
+func (h *PasskeyHandler) DeletePasskey(c *fiber.Ctx) error {
+	passkeyID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return response.BadRequest(c, "Invalid passkey ID", err, nil)
+	}
+
+	account, err := currentAccount(c, h.store)
+	if err != nil {
+		return response.Unauthorized(c, "Account not found", err, nil)
+	}
+
+	// Same guard as unlinking an identity: the account row is locked so
+	// concurrent deletes cannot remove the last two sign-in methods at once.
+	err = h.store.ExecTx(c.Context(), func(q db.Querier) error {
+		lockedAccount, err := q.GetAccountByIdForUpdate(c.Context(), account.ID)
+		if err != nil {
+			return err
+		}
+
+		methods, err := signInMethodCount(c.Context(), q, lockedAccount)
+		if err != nil {
+			return err
+		}
+
+		_, err = q.DeleteWebAuthnCredential(c.Context(), db.DeleteWebAuthnCredentialParams{
+			ID:        passkeyID,
+			AccountID: account.ID,
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errPasskeyNotFound
+			}
+			return err
+		}
+
+		if methods <= 1 {
+			return errLastSignInMethod
+		}
+
+		return nil
+	})
+	if err != nil {
+		switch err {
+		case errPasskeyNotFound:
+			return response.NotFound(c, "Passkey not found", nil, nil)
+		case errLastSignInMethod:
+			return response.BadRequest(c, "Set a password or link an identity before deleting your only sign-in method", nil, nil)
+		}
+		return response.InternalServerError(c, "Failed to delete passkey", err, nil)
+	}
+
+	return response.Success(c, nil, "Passkey deleted successfully")
+}
+
+func (h *PasskeyHandler) webAuthnUser(ctx context.Context, account db.Account) (*service.WebAuthnUser, error) {
+	passkeys, err := h.store.ListWebAuthnCredentials(ctx, account.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		var credential webauthn.Credential
+		if err := json.Unmarshal(passkey.Credential, &credential); err != nil {
+			return nil, fmt.Errorf("failed to decode passkey %s: %w", passkey.ID, err)
+		}
+		credentials = append(credentials, credential)
+	}
+
+	displayName := account.Email
+	if user, err := h.store.GetUserByID(ctx, account.UserID); err == nil {
+		displayName = user.FirstName + " " + user.LastName
+	}
+
+	return &service.WebAuthnUser{
+		ID:          account.ID[:],
+		Name:        account.Email,
+		DisplayName: displayName,
+		Credentials: credentials,
+	}, nil
+}
+
+func (h *PasskeyHandler) saveCeremony(ctx context.Context, accountID uuid.NullUUID, kind string, ceremony passkeyCeremony) (uuid.UUID, error) {
+	data, err := json.Marshal(ceremony)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	session, err := h.store.CreateWebAuthnSession(ctx, db.CreateWebAuthnSessionParams{
+		ID:        uuid.New(),
+		AccountID: accountID,
+		Ceremony:  kind,
+		Data:      data,
+		ExpiresAt: time.Now().Add(passkeyCeremonyTimeout),
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := h.store.DeleteExpiredWebAuthnSessions(ctx); err != nil {
+		log.Printf("Failed to delete expired passkey ceremonies: %v", err)
+	}
+
+	return session.ID, nil
+}
+
+// consumeCeremony loads and deletes a ceremony so each challenge can only be
+// answered once. Registration ceremonies must belong to the current account.
+func (h *PasskeyHandler) consumeCeremony(ctx context.Context, ceremonyID string, kind string, accountID uuid.NullUUID) (passkeyCeremony, error) {
+	id, err := uuid.Parse(ceremonyID)
+	if err != nil {
+		return passkeyCeremony{}, errPasskeyCeremonyExpired
+	}
+
+	session, err := h.store.ConsumeWebAuthnSession(ctx, db.ConsumeWebAuthnSessionParams{
+		ID:       id,
+		Ceremony: kind,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return passkeyCeremony{}, errPasskeyCeremonyExpired
+		}
+		return passkeyCeremony{}, err
+	}
+
+	if time.Now().After(session.ExpiresAt) || session.AccountID != accountID {
+		return passkeyCeremony{}, errPasskeyCeremonyExpired
+	}
+
+	var ceremony passkeyCeremony
+	if err := json.Unmarshal(session.Data, &ceremony); err != nil {
+		return passkeyCeremony{}, err
+	}
+
+	return ceremony, nil
+}
+
+func (h *PasskeyHandler) updateCredentialUsage(ctx context.Context, credential *webauthn.Credential) error {
+	passkey, err := h.store.GetWebAuthnCredentialByCredentialID(ctx, credential.ID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+
+	_, err = h.store.UpdateWebAuthnCredentialUsage(ctx, db.UpdateWebAuthnCredentialUsageParams{
+		ID:         passkey.ID,
+		Credential: data,
+	})
+	return err
+}
@@ -3,6 +3,8 @@ package handler
 import (
 	"database/sql"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -11,6 +13,8 @@ import (
 	"cloud-sprint/config"
 	"cloud-sprint/internal/api/request"
 	"cloud-sprint/internal/api/response"
+	"cloud-sprint/internal/constants"
+	database "cloud-sprint/internal/db"
 	db "cloud-sprint/internal/db/sqlc"
 	"cloud-sprint/internal/service"
 	"cloud-sprint/internal/token"
@@ -18,13 +22,13 @@ import (
 )
 
 type PasswordHandler struct {
-	store        db.Querier
+	store        database.Store
 	tokenMaker   token.Maker
 	config       config.Config
 	emailService *service.EmailService
 }
 
-func NewPasswordHandler(store db.Querier, tokenMaker token.Maker, config config.Config, emailService *service.EmailService) *PasswordHandler {
+func NewPasswordHandler(store database.Store, tokenMaker token.Maker, config config.Config, emailService *service.EmailService) *PasswordHandler {
 	return &PasswordHandler{
 		store:        store,
 		tokenMaker:   tokenMaker,
@@ -60,7 +64,10 @@ func (h *PasswordHandler) ForgotPassword(c *fiber.Ctx) error {
 		return response.InternalServerError(c, "Failed to check email", err, nil)
 	}
 
-	resetToken := util.RandomString(64)
+	resetToken, err := util.GenerateToken(32)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to generate reset token", err, nil)
+	}
 
 	resetID := uuid.New()
 	expires := time.Now().Add(time.Hour)
@@ -68,7 +75,7 @@ func (h *PasswordHandler) ForgotPassword(c *fiber.Ctx) error {
 	_, err = h.store.CreatePasswordReset(c.Context(), db.CreatePasswordResetParams{
 		ID:        resetID,
 		Email:     req.Email,
-		Token:     resetToken,
+		Token:     util.HashToken(resetToken),
 		ExpiresAt: expires,
 	})
 	if err != nil {
@@ -113,7 +120,7 @@ func (h *PasswordHandler) VerifyResetToken(c *fiber.Ctx) error {
 		return response.BadRequest(c, err.Error(), nil, nil)
 	}
 
-	resetRecord, err := h.store.GetPasswordResetByToken(c.Context(), req.Email)
+	resetRecord, err := h.store.GetPasswordResetByToken(c.Context(), util.HashToken(req.Token))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return response.BadRequest(c, "Invalid or expired reset token", nil, nil)
@@ -121,7 +128,7 @@ func (h *PasswordHandler) VerifyResetToken(c *fiber.Ctx) error {
 		return response.InternalServerError(c, "Failed to verify reset token", err, nil)
 	}
 
-	if resetRecord.Token != req.Token {
+	if req.Email != "" && !strings.EqualFold(req.Email, resetRecord.Email) {
 		return response.BadRequest(c, "Invalid or expired reset token", nil, nil)
 	}
 
@@ -147,7 +154,7 @@ func (h *PasswordHandler) ResetPassword(c *fiber.Ctx) error {
 		return response.BadRequest(c, err.Error(), nil, nil)
 	}
 
-	resetRecord, err := h.store.GetPasswordResetByToken(c.Context(), req.Email)
+	resetRecord, err := h.store.GetPasswordResetByToken(c.Context(), util.HashToken(req.Token))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return response.BadRequest(c, "Invalid or expired reset token", nil, nil)
@@ -155,7 +162,7 @@ func (h *PasswordHandler) ResetPassword(c *fiber.Ctx) error {
 		return response.InternalServerError(c, "Failed to verify reset token", err, nil)
 	}
 
-	if resetRecord.Token != req.Token {
+	if req.Email != "" && !strings.EqualFold(req.Email, resetRecord.Email) {
 		return response.BadRequest(c, "Invalid or expired reset token", nil, nil)
 	}
 
@@ -164,23 +171,202 @@ func (h *PasswordHandler) ResetPassword(c *fiber.Ctx) error {
 		return response.InternalServerError(c, "Failed to get account", err, nil)
 	}
 
-	hashedPassword, err := util.HashPassword(req.Password)
+	user, err := h.store.GetUserByID(c.Context(), account.UserID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to get user", err, nil)
+	}
+
+	if violations := checkPasswordPolicy(h.config, req.Password, account.Email, user.FirstName, user.LastName); len(violations) > 0 {
+		return passwordPolicyFailed(c, violations)
+	}
+
+	hashedPassword, err := passwordHasher(h.config).Hash(req.Password)
 	if err != nil {
 		return response.InternalServerError(c, "Failed to hash password", err, nil)
 	}
 
-	_, err = h.store.UpdateAccountPassword(c.Context(), db.UpdateAccountPasswordParams{
-		ID:             account.ID,
-		HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
+	// Consuming the token, storing the new hash and blocking every session
+	// either all happen or none do, so a token can only ever be used once.
+	err = h.store.ExecTx(c.Context(), func(q db.Querier) error {
+		if _, err := q.ConsumePasswordReset(c.Context(), resetRecord.ID); err != nil {
+			return err
+		}
+
+		_, err := q.UpdateAccountPassword(c.Context(), db.UpdateAccountPasswordParams{
+			ID:             account.ID,
+			HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		return q.BlockAccountSessions(c.Context(), account.ID)
 	})
 	if err != nil {
-		return response.InternalServerError(c, "Failed to update password", err, nil)
+		if err == sql.ErrNoRows {
+			return response.BadRequest(c, "Invalid or expired reset token", nil, nil)
+		}
+		return response.InternalServerError(c, "Failed to reset password", err, nil)
 	}
 
-	err = h.store.MarkPasswordResetUsed(c.Context(), resetRecord.ID)
+	err = h.emailService.SendEmail(service.EmailData{
+		To:       account.Email,
+		Subject:  "Your password was reset",
+		Template: "password_changed.html",
+		Data: map[string]interface{}{
+			"Name":      account.Email,
+			"IPAddress": c.IP(),
+		},
+	})
 	if err != nil {
-		return response.InternalServerError(c, "Failed to mark token as used", err, nil)
+		log.Printf("Failed to send password reset confirmation email: %v", err)
 	}
 
 	return response.Success(c, nil, "Your password has been reset successfully")
 }
+
+// ChangePassword changes the password of the authenticated user
+// @Summary Change password
+// @Description Change the password with the current one, sign out every other session and send a notification email. Wrong current passwords count towards the sign-in lockout
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body request.ChangePasswordRequest true "Change password request"
+// @Security BearerAuth
+// @Success 200 {object} response.BaseResponse
+// @Router /auth/change-password [post]
+func (h *PasswordHandler) ChangePassword(c *fiber.Ctx) error {
+	var req request.ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request body", err, nil)
+	}
+
+	if err := req.Validate(); err != nil {
+		return response.BadRequest(c, err.Error(), nil, nil)
+	}
+
+	currentSessionID, ok := c.Locals("current_session_id").(string)
+	if !ok {
+		return response.Unauthorized(c, "session not found", nil, nil)
+	}
+
+	sessionUUID, err := uuid.Parse(currentSessionID)
+	if err != nil {
+		return response.BadRequest(c, "invalid session id", err, nil)
+	}
+
+	account, err := currentAccount(c, h.store)
+	if err != nil {
+		return response.Unauthorized(c, "Account not found", err, nil)
+	}
+
+	if !account.HashedPassword.Valid {
+		return response.Unauthorized(c, "Current password is incorrect", nil, nil)
+	}
+
+	// A stolen access token must not allow unlimited guesses at the current
+	// password, so wrong guesses count towards the same lockout as sign-in.
+	if account.LockedUntil.Valid && time.Now().Before(account.LockedUntil.Time) {
+		return accountLocked(c, account.LockedUntil.Time)
+	}
+
+	if util.CheckPassword(req.CurrentPassword, account.HashedPassword.String) != nil {
+		if lockedUntil, locked := recordFailedLogin(c, h.store, h.emailService, h.config, account); locked {
+			return accountLocked(c, lockedUntil)
+		}
+		return response.Unauthorized(c, "Current password is incorrect", nil, nil)
+	}
+
+	resetFailedLogins(c, h.store, account)
+
+	user, err := h.store.GetUserByID(c.Context(), account.UserID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to get user", err, nil)
+	}
+
+	if violations := checkPasswordPolicy(h.config, req.NewPassword, account.Email, user.FirstName, user.LastName); len(violations) > 0 {
+		return passwordPolicyFailed(c, violations)
+	}
+
+	hashedPassword, err := passwordHasher(h.config).Hash(req.NewPassword)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to hash password", err, nil)
+	}
+
+	// The new hash and the sign-out of every other session are stored
+	// together, so a failure cannot leave a stolen session signed in with the
+	// password already changed.
+	err = h.store.ExecTx(c.Context(), func(q db.Querier) error {
+		_, err := q.UpdateAccountPassword(c.Context(), db.UpdateAccountPasswordParams{
+			ID:             account.ID,
+			HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		return q.RevokeOtherSessions(c.Context(), db.RevokeOtherSessionsParams{
+			AccountID: account.ID,
+			ID:        sessionUUID,
+		})
+	})
+	if err != nil {
+		return response.InternalServerError(c, "Failed to change password", err, nil)
+	}
+
+	err = h.emailService.SendEmail(service.EmailData{
+		To:       account.Email,
+		Subject:  "Your password was changed",
+		Template: "password_changed.html",
+		Data: map[string]interface{}{
+			"Name":      account.Email,
+			"IPAddress": c.IP(),
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to send password changed email: %v", err)
+	}
+
+	return response.Success(c, nil, "Your password has been changed successfully")
+}
+
+func passwordPolicy(config config.Config) util.PasswordPolicy {
+	return util.PasswordPolicy{
+		MinLength:     config.Password.MinLength,
+		RequireUpper:  config.Password.RequireUpper,
+		RequireLower:  config.Password.RequireLower,
+		RequireDigit:  config.Password.RequireDigit,
+		RequireSymbol: config.Password.RequireSymbol,
+		BreachListDir: config.Password.BreachListDir,
+	}
+}
+
+func passwordHasher(config config.Config) util.PasswordHasher {
+	return util.NewPasswordHasher(
+		config.Password.HashAlgorithm,
+		util.Argon2idHasher{
+			Memory:      config.Password.Argon2Memory,
+			Iterations:  config.Password.Argon2Iterations,
+			Parallelism: config.Password.Argon2Parallelism,
+			SaltLength:  16,
+			KeyLength:   32,
+		},
+		util.BcryptHasher{Cost: config.Password.BcryptCost},
+	)
+}
+
+// checkPasswordPolicy returns the rules a new password breaks. A breach list
+// that cannot be read is logged and skipped so sign-ups keep working.
+func checkPasswordPolicy(config config.Config, password string, personalInfo ...string) []util.PasswordViolation {
+	violations, err := passwordPolicy(config).Validate(password, personalInfo...)
+	if err != nil {
+		log.Printf("Failed to check breached passwords: %v", err)
+	}
+
+	return violations
+}
+
+func passwordPolicyFailed(c *fiber.Ctx, violations []util.PasswordViolation) error {
+	errorCode := constants.WEAK_PASSWORD
+	return response.BadRequestWithData(c, "Password does not meet the requirements", violations, &errorCode)
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/oauth2"
+
+	"cloud-sprint/config"
+	"cloud-sprint/internal/api/response"
+	db "cloud-sprint/internal/db/sqlc"
+	"cloud-sprint/internal/service"
+)
+
+var errProviderNotConnected = errors.New("provider account not connected")
+
+type RepositoryHandler struct {
+	store     db.Querier
+	config    config.Config
+	providers *service.OAuthProviderRegistry
+}
+
+func NewRepositoryHandler(store db.Querier, config config.Config, providers *service.OAuthProviderRegistry) *RepositoryHandler {
+	return &RepositoryHandler{
+		store:     store,
+		config:    config,
+		providers: providers,
+	}
+}
+
+// ListRepositories returns the repositories of every connected code host
+// @Summary List repositories
+// @Description Get the repositories of the authenticated user from every connected GitHub, GitLab and Bitbucket account
+// @Tags repositories
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} response.RepositoryResponse
+// @Router /repositories [get]
+func (h *RepositoryHandler) ListRepositories(c *fiber.Ctx) error {
+	account, err := currentAccount(c, h.store)
+	if err != nil {
+		return response.Unauthorized(c, "Account not found", err, nil)
+	}
+
+	oauthAccounts, err := h.store.GetOAuthAccountsByAccountID(c.Context(), account.ID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to get OAuth accounts", err, nil)
+	}
+
+	repos := []response.RepositoryResponse{}
+	for _, oauthAccount := range oauthAccounts {
+		provider, ok := h.repositoryProvider(oauthAccount.Provider)
+		if !ok {
+			continue
+		}
+
+		providerRepos, err := h.fetchRepositories(c.Context(), provider, oauthAccount)
+		if err == errProviderNotConnected {
+			continue
+		}
+		if err != nil {
+			return response.InternalServerError(c, "Failed to fetch "+provider.Name()+" repositories", err, nil)
+		}
+
+		repos = append(repos, response.NewRepositoriesResponse(provider.Name(), providerRepos)...)
+	}
+
+	return response.Success(c, repos, "Repositories retrieved successfully")
+}
+
+// ListProviderRepositories returns the repositories of one code host
+// @Summary List repositories of a provider
+// @Description Get the repositories of the authenticated user from one connected code host
+// @Tags repositories
+// @Produce json
+// @Param provider path string true "Provider name: github, gitlab or bitbucket"
+// @Security BearerAuth
+// @Success 200 {array} response.RepositoryResponse
+// @Router /repositories/{provider} [get]
+func (h *RepositoryHandler) ListProviderRepositories(c *fiber.Ctx) error {
+	provider, ok := h.repositoryProvider(c.Params("provider"))
+	if !ok {
+		return response.NotFound(c, "Unknown repository provider", nil, nil)
+	}
+
+	account, err := currentAccount(c, h.store)
+	if err != nil {
+		return response.Unauthorized(c, "Account not found", err, nil)
+	}
+
+	oauthAccount, err := h.store.GetOAuthAccountByAccountIDAndProvider(c.Context(), db.GetOAuthAccountByAccountIDAndProviderParams{
+		AccountID: account.ID,
+		Provider:  provider.Name(),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return response.BadRequest(c, "Account not connected to "+provider.Name(), nil, nil)
+		}
+		return response.InternalServerError(c, "Failed to get OAuth account", err, nil)
+	}
+
+	repos, err := h.fetchRepositories(c.Context(), provider, oauthAccount)
+	if err != nil {
+		if err == errProviderNotConnected {
+			return response.BadRequest(c, "No valid "+provider.Name()+" token found", nil, nil)
+		}
+		return response.InternalServerError(c, "Failed to fetch "+provider.Name()+" repositories", err, nil)
+	}
+
+	return response.Success(c, response.NewRepositoriesResponse(provider.Name(), repos), "Repositories retrieved successfully")
+}
+
+func (h *RepositoryHandler) repositoryProvider(name string) (service.RepositoryProvider, bool) {
+	provider, ok := h.providers.Get(name)
+	if !ok {
+		return nil, false
+	}
+
+	repositoryProvider, ok := provider.(service.RepositoryProvider)
+	return repositoryProvider, ok
+}
+
+func (h *RepositoryHandler) fetchRepositories(ctx context.Context, provider service.RepositoryProvider, oauthAccount db.OauthAccount) ([]service.Repository, error) {
+	token, err := h.providerToken(ctx, provider, oauthAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	return provider.GetUserRepositories(ctx, token)
+}
+
+// providerToken returns the stored token of an OAuth account, refreshing it
+// through the provider and saving the new one once it has expired.
+func (h *RepositoryHandler) providerToken(ctx context.Context, provider service.OAuthProvider, oauthAccount db.OauthAccount) (*oauth2.Token, error) {
+	if !oauthAccount.AccessToken.Valid {
+		return nil, errProviderNotConnected
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  oauthAccount.AccessToken.String,
+		TokenType:    "Bearer",
+		RefreshToken: oauthAccount.RefreshToken.String,
+	}
+
+	if oauthAccount.ExpiresAt.Valid {
+		token.Expiry = oauthAccount.ExpiresAt.Time
+	}
+
+	if token.Valid() || !oauthAccount.RefreshToken.Valid {
+		return token, nil
+	}
+
+	newToken, err := provider.OAuthConfig().TokenSource(ctx, token).Token()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = h.store.UpdateOAuthAccount(ctx, db.UpdateOAuthAccountParams{
+		ID:           oauthAccount.ID,
+		AccessToken:  sql.NullString{String: newToken.AccessToken, Valid: true},
+		RefreshToken: sql.NullString{String: newToken.RefreshToken, Valid: newToken.RefreshToken != ""},
+		ExpiresAt:    sql.NullTime{Time: newToken.Expiry, Valid: !newToken.Expiry.IsZero()},
+		UpdatedAt:    time.Now(),
+	})
+	if err != nil {
+		log.Printf("Failed to update OAuth account: %v", err)
+	}
+
+	return newToken, nil
+}
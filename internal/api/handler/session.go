@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"cloud-sprint/config"
+	"cloud-sprint/internal/api/response"
+	db "cloud-sprint/internal/db/sqlc"
+	"cloud-sprint/internal/token"
+)
+
+type SessionHandler struct {
+	store      db.Querier
+	tokenMaker token.Maker
+	config     config.Config
+}
+
+func NewSessionHandler(store db.Querier, tokenMaker token.Maker, config config.Config) *SessionHandler {
+	return &SessionHandler{
+		store:      store,
+		tokenMaker: tokenMaker,
+		config:     config,
+	}
+}
+
+// ListSessions returns the active sessions of the authenticated user
+// @Summary List sessions
+// @Description List the active sessions of the authenticated user, flagging the current one. Revoked, blocked, signed out and expired sessions are left out
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} response.SessionResponse
+// @Router /auth/sessions [get]
+func (h *SessionHandler) ListSessions(c *fiber.Ctx) error {
+	account, err := currentAccount(c, h.store)
+	if err != nil {
+		return response.Unauthorized(c, "Account not found", err, nil)
+	}
+
+	sessions, err := h.store.ListSessions(c.Context(), account.ID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to list sessions", err, nil)
+	}
+
+	currentSessionID, _ := c.Locals("current_session_id").(string)
+
+	return response.Success(c, response.NewSessionsResponse(sessions, currentSessionID), "Sessions retrieved successfully")
+}
+
+// RevokeSession revokes one of the authenticated user's sessions
+// @Summary Revoke session
+// @Description Revoke a session of the authenticated user by ID
+// @Tags auth
+// @Produce json
+// @Param id path string true "Session ID"
+// @Security BearerAuth
+// @Success 200 {object} response.BaseResponse
+// @Router /auth/sessions/{id} [delete]
+func (h *SessionHandler) RevokeSession(c *fiber.Ctx) error {
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return response.BadRequest(c, "Invalid session ID", err, nil)
+	}
+
+	account, err := currentAccount(c, h.store)
+	if err != nil {
+		return response.Unauthorized(c, "Account not found", err, nil)
+	}
+
+	_, err = h.store.RevokeSession(c.Context(), db.RevokeSessionParams{
+		ID:        sessionID,
+		AccountID: account.ID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return response.NotFound(c, "Session not found", nil, nil)
+		}
+		return response.InternalServerError(c, "Failed to revoke session", err, nil)
+	}
+
+	return response.Success(c, nil, "Session revoked successfully")
+}
+
+// RevokeOtherSessions signs the authenticated user out everywhere else
+// @Summary Revoke other sessions
+// @Description Revoke every session of the authenticated user except the current one
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.BaseResponse
+// @Router /auth/sessions/revoke-others [post]
+func (h *SessionHandler) RevokeOtherSessions(c *fiber.Ctx) error {
+	currentSessionID, ok := c.Locals("current_session_id").(string)
+	if !ok {
+		return response.Unauthorized(c, "session not found", nil, nil)
+	}
+
+	sessionUUID, err := uuid.Parse(currentSessionID)
+	if err != nil {
+		return response.BadRequest(c, "invalid session id", err, nil)
+	}
+
+	account, err := currentAccount(c, h.store)
+	if err != nil {
+		return response.Unauthorized(c, "Account not found", err, nil)
+	}
+
+	err = h.store.RevokeOtherSessions(c.Context(), db.RevokeOtherSessionsParams{
+		AccountID: account.ID,
+		ID:        sessionUUID,
+	})
+	if err != nil {
+		return response.InternalServerError(c, "Failed to revoke sessions", err, nil)
+	}
+
+	return response.Success(c, nil, "Signed out of all other sessions")
+}
+
+// currentAccount loads the account of the user set by the auth middleware.
+func currentAccount(c *fiber.Ctx, store db.Querier) (db.Account, error) {
+	userID, ok := c.Locals("current_user_id").(string)
+	if !ok {
+		return db.Account{}, errors.New("user not found")
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return db.Account{}, err
+	}
+
+	return store.GetAccountByUserId(c.Context(), userUUID)
+}
@@ -1,12 +1,16 @@
 package middleware
 
 import (
+	"database/sql"
+	"log"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 
 	"cloud-sprint/config"
+	"cloud-sprint/internal/api/request"
 	db "cloud-sprint/internal/db/sqlc"
 	"cloud-sprint/internal/token"
 	"cloud-sprint/pkg/util"
@@ -17,10 +21,11 @@ type AuthMiddleware struct {
 	tokenType  string
 	tokenName  string
 	store      db.Querier
+	denylist   token.Denylist
 	config     config.Config
 }
 
-func NewAuthMiddleware(tokenMaker token.Maker, tokenType string, store db.Querier, config config.Config) fiber.Handler {
+func NewAuthMiddleware(tokenMaker token.Maker, tokenType string, store db.Querier, denylist token.Denylist, config config.Config) fiber.Handler {
 	tokenName := "Authorization"
 	if tokenType == "refresh" {
 		tokenName = "Refresh"
@@ -31,6 +36,7 @@ func NewAuthMiddleware(tokenMaker token.Maker, tokenType string, store db.Querie
 		tokenType:  tokenType,
 		tokenName:  tokenName,
 		store:      store,
+		denylist:   denylist,
 		config:     config,
 	}
 
@@ -81,45 +87,19 @@ func (middleware *AuthMiddleware) Handle(c *fiber.Ctx) error {
 			if refreshToken != "" {
 				refreshPayload, verifyErr := middleware.tokenMaker.VerifyRefreshToken(refreshToken)
 				if verifyErr == nil {
-					userID, parseErr := uuid.Parse(refreshPayload.UserID)
-					if parseErr == nil {
-						newAccessToken, _, tokenErr := middleware.tokenMaker.CreateToken(
-							userID,
-							refreshPayload.Email,
-							middleware.config.JWT.TokenDuration,
-						)
-						
-						if tokenErr == nil {
-							newRefreshToken, refreshPayload, tokenErr := middleware.tokenMaker.CreateRefreshToken(
-								userID,
-								refreshPayload.Email,
-								middleware.config.JWT.RefreshDuration,
-							)
-							
-							if tokenErr == nil {
-								util.SetHttpOnlyCookie(c, util.SetCookieData{
-									Name:      "Authorization",
-									Token:     newAccessToken,
-									ExpiresAt: int(middleware.config.JWT.TokenDuration.Seconds()),
-									ENV:       middleware.config.Environment,
-								})
-								
-								util.SetHttpOnlyCookie(c, util.SetCookieData{
-									Name:      "Refresh",
-									Token:     newRefreshToken,
-									ExpiresAt: int(middleware.config.JWT.RefreshDuration.Seconds()),
-									ENV:       middleware.config.Environment,
-								})
-								
-								c.Set("Authorization", "Bearer "+newAccessToken)
-								
-								c.Locals("current_user_id", refreshPayload.UserID)
-								c.Locals("current_user_email", refreshPayload.Email)
-								
-								return c.Next()
-							}
-						}
+					session, sessionErr := middleware.validateSession(c, refreshPayload)
+					if sessionErr != nil {
+						return sessionErr
 					}
+
+					accessPayload, rotateErr := middleware.rotateRefreshToken(c, session, refreshPayload, refreshToken)
+					if rotateErr != nil {
+						return rotateErr
+					}
+
+					middleware.setCurrentUser(c, accessPayload)
+
+					return c.Next()
 				}
 			}
 			
@@ -134,11 +114,270 @@ func (middleware *AuthMiddleware) Handle(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusUnauthorized, "invalid "+middleware.tokenType+" token")
 	}
 
+	session, err := middleware.validateSession(c, payload)
+	if err != nil {
+		return err
+	}
+
+	if middleware.tokenType == "refresh" && middleware.store != nil {
+		if err := checkRefreshRequest(c, payload); err != nil {
+			return err
+		}
+
+		payload, err = middleware.rotateRefreshToken(c, session, payload, tokenString)
+		if err != nil {
+			return err
+		}
+	}
+
+	middleware.setCurrentUser(c, payload)
+
+	return c.Next()
+}
+
+func (middleware *AuthMiddleware) setCurrentUser(c *fiber.Ctx, payload *token.Payload) {
 	c.Locals("current_user_id", payload.UserID)
 	c.Locals("current_user_email", payload.Email)
-	if middleware.tokenType == "refresh" {
-		c.Locals("refresh_token", tokenString)
+	c.Locals("current_session_id", payload.SessionID)
+	c.Locals("current_token_id", payload.ID)
+}
+
+// validateSession rejects tokens that were revoked or whose session was
+// signed out, revoked, blocked or has expired.
+func (middleware *AuthMiddleware) validateSession(c *fiber.Ctx, payload *token.Payload) (db.Session, error) {
+	if middleware.denylist != nil {
+		revoked, err := middleware.denylist.Contains(c.Context(), payload.ID)
+		if err != nil {
+			return db.Session{}, fiber.NewError(fiber.StatusInternalServerError, "failed to check token revocation")
+		}
+		if revoked {
+			return db.Session{}, fiber.NewError(fiber.StatusUnauthorized, middleware.tokenType+" token has been revoked")
+		}
+
+		signedOut, err := middleware.denylist.Contains(c.Context(), payload.SessionID)
+		if err != nil {
+			return db.Session{}, fiber.NewError(fiber.StatusInternalServerError, "failed to check token revocation")
+		}
+		if signedOut {
+			return db.Session{}, fiber.NewError(fiber.StatusUnauthorized, "session has been signed out")
+		}
 	}
 
-	return c.Next()
+	if middleware.store == nil {
+		return db.Session{}, nil
+	}
+
+	sessionID, err := uuid.Parse(payload.SessionID)
+	if err != nil {
+		return db.Session{}, fiber.NewError(fiber.StatusUnauthorized, "invalid "+middleware.tokenType+" token")
+	}
+
+	session, err := middleware.store.GetSession(c.Context(), sessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return db.Session{}, fiber.NewError(fiber.StatusUnauthorized, "session has been revoked")
+		}
+		return db.Session{}, fiber.NewError(fiber.StatusInternalServerError, "failed to get session")
+	}
+
+	if session.IsBlocked {
+		return db.Session{}, fiber.NewError(fiber.StatusUnauthorized, "session is blocked")
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		return db.Session{}, fiber.NewError(fiber.StatusUnauthorized, "session has expired")
+	}
+
+	return session, nil
+}
+
+// checkRefreshRequest validates the body of the refresh route before the token
+// is rotated, so a rejected request never leaves the client holding a refresh
+// token that was already rotated away.
+func checkRefreshRequest(c *fiber.Ctx, payload *token.Payload) error {
+	var req request.RefreshTokenRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		}
+	}
+
+	if err := req.Validate(); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if req.SessionID != "" && req.SessionID != payload.SessionID {
+		return fiber.NewError(fiber.StatusUnauthorized, "invalid refresh token")
+	}
+
+	return nil
+}
+
+// rotateRefreshToken issues a new token pair for the session and swaps the
+// stored refresh token hash. Only the refresh token currently stored on the
+// session is accepted, and this is the only place reuse is detected: both the
+// refresh route and the silent refresh of expired access tokens go through it.
+// The new tokens are set as cookies and left in the access_token and
+// refresh_token locals for the handler.
+//
+// A token that was rotated moments ago is not treated as reuse, since clients
+// firing parallel refreshes race for the same token. The losers get an access
+// token for the session and keep the refresh token issued to the winner.
+func (middleware *AuthMiddleware) rotateRefreshToken(c *fiber.Ctx, session db.Session, refreshPayload *token.Payload, refreshToken string) (*token.Payload, error) {
+	if !util.CheckTokenHash(refreshToken, session.RefreshToken) {
+		if middleware.rotatedWithinGrace(session, refreshToken) {
+			return middleware.issueAccessToken(c, session, refreshPayload)
+		}
+		return nil, middleware.revokeSessionFamily(c, session)
+	}
+
+	userID, err := uuid.Parse(refreshPayload.UserID)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "invalid "+middleware.tokenType+" token")
+	}
+
+	newAccessToken, accessPayload, err := middleware.tokenMaker.CreateToken(
+		userID,
+		refreshPayload.Email,
+		session.ID,
+		middleware.config.JWT.TokenDuration,
+	)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to create access token")
+	}
+
+	newRefreshToken, newRefreshPayload, err := middleware.tokenMaker.CreateRefreshToken(
+		userID,
+		refreshPayload.Email,
+		session.ID,
+		middleware.config.JWT.RefreshDuration,
+	)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to create refresh token")
+	}
+
+	// The update only matches the stored hash, so when the same token is
+	// presented twice concurrently only one request rotates it.
+	_, err = middleware.store.RotateSessionRefreshToken(c.Context(), db.RotateSessionRefreshTokenParams{
+		ID:              session.ID,
+		RefreshToken:    util.HashToken(refreshToken),
+		NewRefreshToken: util.HashToken(newRefreshToken),
+		ExpiresAt:       newRefreshPayload.ExpiredAt,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return middleware.lostRotationRace(c, session.ID, refreshPayload, refreshToken)
+		}
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to update session")
+	}
+
+	util.SetHttpOnlyCookie(c, util.SetCookieData{
+		Name:      "Authorization",
+		Token:     newAccessToken,
+		ExpiresAt: int(middleware.config.JWT.TokenDuration.Seconds()),
+		ENV:       middleware.config.Environment,
+	})
+
+	util.SetHttpOnlyCookie(c, util.SetCookieData{
+		Name:      "Refresh",
+		Token:     newRefreshToken,
+		ExpiresAt: int(middleware.config.JWT.RefreshDuration.Seconds()),
+		ENV:       middleware.config.Environment,
+	})
+
+	c.Set("Authorization", "Bearer "+newAccessToken)
+
+	c.Locals("access_token", newAccessToken)
+	c.Locals("refresh_token", newRefreshToken)
+
+	return accessPayload, nil
+}
+
+// lostRotationRace handles a rotation whose conditional update matched no row
+// because another request rotated the same token between the read and the
+// update, or because the session was blocked or revoked in the meantime.
+func (middleware *AuthMiddleware) lostRotationRace(c *fiber.Ctx, sessionID uuid.UUID, refreshPayload *token.Payload, refreshToken string) (*token.Payload, error) {
+	session, err := middleware.store.GetSession(c.Context(), sessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fiber.NewError(fiber.StatusUnauthorized, "session has been revoked")
+		}
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to get session")
+	}
+
+	if session.IsBlocked {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "session is blocked")
+	}
+
+	if !middleware.rotatedWithinGrace(session, refreshToken) {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "invalid "+middleware.tokenType+" token")
+	}
+
+	return middleware.issueAccessToken(c, session, refreshPayload)
+}
+
+// rotatedWithinGrace reports whether refreshToken is the one the session held
+// before its last rotation and that rotation happened within the grace period.
+func (middleware *AuthMiddleware) rotatedWithinGrace(session db.Session, refreshToken string) bool {
+	if session.PreviousRefreshToken == "" || !session.RotatedAt.Valid {
+		return false
+	}
+
+	if time.Since(session.RotatedAt.Time) > middleware.config.JWT.RefreshReuseGrace {
+		return false
+	}
+
+	return util.CheckTokenHash(refreshToken, session.PreviousRefreshToken)
+}
+
+// issueAccessToken issues only an access token for the session, leaving the
+// refresh token the winning request received in place. The refresh_token
+// local is left empty.
+func (middleware *AuthMiddleware) issueAccessToken(c *fiber.Ctx, session db.Session, refreshPayload *token.Payload) (*token.Payload, error) {
+	userID, err := uuid.Parse(refreshPayload.UserID)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "invalid "+middleware.tokenType+" token")
+	}
+
+	newAccessToken, accessPayload, err := middleware.tokenMaker.CreateToken(
+		userID,
+		refreshPayload.Email,
+		session.ID,
+		middleware.config.JWT.TokenDuration,
+	)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to create access token")
+	}
+
+	util.SetHttpOnlyCookie(c, util.SetCookieData{
+		Name:      "Authorization",
+		Token:     newAccessToken,
+		ExpiresAt: int(middleware.config.JWT.TokenDuration.Seconds()),
+		ENV:       middleware.config.Environment,
+	})
+
+	c.Set("Authorization", "Bearer "+newAccessToken)
+
+	c.Locals("access_token", newAccessToken)
+
+	return accessPayload, nil
+}
+
+// revokeSessionFamily blocks a session whose already rotated refresh token was
+// presented again, since every token issued for it must be treated as stolen.
+func (middleware *AuthMiddleware) revokeSessionFamily(c *fiber.Ctx, session db.Session) error {
+	log.Printf("security: refresh token reuse detected for session %s of account %s from %s (%s)",
+		session.ID, session.AccountID, c.IP(), c.Get("User-Agent"))
+
+	if _, err := middleware.store.BlockSession(c.Context(), session.ID); err != nil {
+		log.Printf("Failed to block session %s: %v", session.ID, err)
+	}
+
+	if middleware.denylist != nil {
+		if err := middleware.denylist.Add(c.Context(), session.ID.String(), session.ExpiresAt); err != nil {
+			log.Printf("Failed to revoke session %s: %v", session.ID, err)
+		}
+	}
+
+	return fiber.NewError(fiber.StatusUnauthorized, "refresh token reuse detected")
 }
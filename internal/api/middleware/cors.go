@@ -10,7 +10,7 @@ func CORS() fiber.Handler {
 		AllowOrigins:     "http://localhost:3000",
 		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS,PATCH",
 		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,Refresh,X-Requested-With,X-Request-ID,Idempotency-Key",
-		ExposeHeaders:    "Content-Length,Content-Type,X-Request-ID",
+		ExposeHeaders:    "Content-Length,Content-Type,X-Request-ID,Retry-After,X-RateLimit-Limit,X-RateLimit-Remaining,X-RateLimit-Reset",
 		AllowCredentials: true,
 		MaxAge:           86400,
 	})
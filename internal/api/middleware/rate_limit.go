@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"cloud-sprint/internal/api/response"
+	"cloud-sprint/internal/constants"
+	"cloud-sprint/pkg/util"
+)
+
+type RateLimitKey string
+
+const (
+	RateLimitByIP     RateLimitKey = "ip"
+	RateLimitByEmail  RateLimitKey = "email"
+	RateLimitByUserID RateLimitKey = "user"
+	// RateLimitByMFAToken keys by the MFA token of a pending sign-in, capping
+	// the codes that can be tried against one password step.
+	RateLimitByMFAToken RateLimitKey = "mfa_token"
+)
+
+// RateLimitRule allows Limit requests per Window for each distinct key.
+// Requests without a value for the key (no email in the body, no signed in
+// user) are not counted by the rule, so limiters keyed by user must be mounted
+// after the auth middleware.
+type RateLimitRule struct {
+	KeyBy  RateLimitKey
+	Limit  int
+	Window time.Duration
+}
+
+type RateLimitPolicy struct {
+	Name  string
+	Rules []RateLimitRule
+}
+
+// RateLimitStore counts hits per key in fixed windows. A Redis backed store
+// can implement it with INCR and PEXPIRE so limits are shared between instances.
+type RateLimitStore interface {
+	Increment(ctx context.Context, key string, window time.Duration) (int, time.Time, error)
+}
+
+type rateLimitEntry struct {
+	count   int
+	resetAt time.Time
+}
+
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	entries   map[string]*rateLimitEntry
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		entries:   make(map[string]*rateLimitEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *MemoryRateLimitStore) Increment(_ context.Context, key string, window time.Duration) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, entry := range s.entries {
+			if now.After(entry.resetAt) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	entry, ok := s.entries[key]
+	if !ok || now.After(entry.resetAt) {
+		entry = &rateLimitEntry{resetAt: now.Add(window)}
+		s.entries[key] = entry
+	}
+	entry.count++
+
+	return entry.count, entry.resetAt, nil
+}
+
+func NewRateLimiter(store RateLimitStore, policy RateLimitPolicy) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		limit := 0
+		remaining := -1
+		var resetAt time.Time
+
+		for _, rule := range policy.Rules {
+			value := rateLimitKeyValue(c, rule.KeyBy)
+			if value == "" {
+				continue
+			}
+
+			key := strings.Join([]string{"ratelimit", policy.Name, string(rule.KeyBy), value}, ":")
+			count, ruleResetAt, err := store.Increment(c.Context(), key, rule.Window)
+			if err != nil {
+				log.Printf("Failed to apply rate limit %s: %v", policy.Name, err)
+				continue
+			}
+
+			ruleRemaining := rule.Limit - count
+			if ruleRemaining < 0 {
+				ruleRemaining = 0
+			}
+
+			if remaining == -1 || ruleRemaining < remaining {
+				limit = rule.Limit
+				remaining = ruleRemaining
+				resetAt = ruleResetAt
+			}
+
+			if count > rule.Limit {
+				setRateLimitHeaders(c, rule.Limit, 0, ruleResetAt)
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(secondsUntil(ruleResetAt)))
+
+				errorCode := constants.RATE_LIMITED
+				return response.TooManyRequests(c, "Too many requests, please try again later", &errorCode)
+			}
+		}
+
+		if remaining >= 0 {
+			setRateLimitHeaders(c, limit, remaining, resetAt)
+		}
+
+		return c.Next()
+	}
+}
+
+func rateLimitKeyValue(c *fiber.Ctx, keyBy RateLimitKey) string {
+	switch keyBy {
+	case RateLimitByIP:
+		return c.IP()
+	case RateLimitByUserID:
+		userID, _ := c.Locals("current_user_id").(string)
+		return userID
+	case RateLimitByEmail:
+		var body struct {
+			Email string `json:"email"`
+		}
+		if err := json.Unmarshal(c.Body(), &body); err != nil {
+			return ""
+		}
+		return strings.ToLower(strings.TrimSpace(body.Email))
+	case RateLimitByMFAToken:
+		var body struct {
+			MFAToken string `json:"mfaToken"`
+		}
+		if err := json.Unmarshal(c.Body(), &body); err != nil || body.MFAToken == "" {
+			return ""
+		}
+		return util.HashToken(body.MFAToken)
+	default:
+		return ""
+	}
+}
+
+func setRateLimitHeaders(c *fiber.Ctx, limit int, remaining int, resetAt time.Time) {
+	c.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	c.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	c.Set("X-RateLimit-Reset", strconv.Itoa(secondsUntil(resetAt)))
+}
+
+func secondsUntil(t time.Time) int {
+	seconds := int(time.Until(t).Seconds()) + 1
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
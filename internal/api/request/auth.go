@@ -1,10 +1,11 @@
 package request
 
 import (
-	"cloud-sprint/internal/api/response"
 	"errors"
 	"net/mail"
 	"strings"
+
+	"github.com/google/uuid"
 )
 
 type SignUpRequest struct {
@@ -16,19 +17,19 @@ type SignUpRequest struct {
 
 func (r *SignUpRequest) Validate() error {
 	if strings.Contains(r.FirstName, " ") {
-		return response.BadRequest(nil, "first name cannot contain spaces", nil, nil)
+		return errors.New("first name cannot contain spaces")
 	}
 
 	if strings.Contains(r.LastName, " ") {
-		return response.BadRequest(nil, "last name cannot contain spaces", nil, nil)
+		return errors.New("last name cannot contain spaces")
 	}
 
 	if _, err := mail.ParseAddress(r.Email); err != nil {
-		return response.BadRequest(nil, "invalid email address", nil, nil)
+		return errors.New("invalid email address")
 	}
 
-	if len(r.Password) < 6 {
-		return response.BadRequest(nil, "password must be at least 6 characters long", nil, nil)
+	if r.Password == "" {
+		return errors.New("password is required")
 	}
 
 	return nil
@@ -41,11 +42,11 @@ type SignInRequest struct {
 
 func (r *SignInRequest) Validate() error {
 	if r.Email == "" {
-		return response.BadRequest(nil, "email is required", nil, nil)
+		return errors.New("email is required")
 	}
 
 	if r.Password == "" {
-		return response.BadRequest(nil, "password is required", nil, nil)
+		return errors.New("password is required")
 	}
 
 	return nil
@@ -56,8 +57,10 @@ type RefreshTokenRequest struct {
 }
 
 func (r *RefreshTokenRequest) Validate() error {
-	if r.SessionID == "" {
-		return response.BadRequest(nil, "Session ID is required", nil, nil)
+	if r.SessionID != "" {
+		if _, err := uuid.Parse(r.SessionID); err != nil {
+			return errors.New("invalid session ID")
+		}
 	}
 
 	return nil
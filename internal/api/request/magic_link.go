@@ -0,0 +1,34 @@
+package request
+
+import (
+	"errors"
+	"net/mail"
+)
+
+type MagicLinkRequest struct {
+	Email string `json:"email"`
+}
+
+func (r *MagicLinkRequest) Validate() error {
+	if r.Email == "" {
+		return errors.New("email is required")
+	}
+
+	if _, err := mail.ParseAddress(r.Email); err != nil {
+		return errors.New("invalid email address")
+	}
+
+	return nil
+}
+
+type ConsumeMagicLinkRequest struct {
+	Token string `json:"token"`
+}
+
+func (r *ConsumeMagicLinkRequest) Validate() error {
+	if r.Token == "" {
+		return errors.New("token is required")
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package request
+
+import (
+	"errors"
+)
+
+type VerifyMFARequest struct {
+	MFAToken     string `json:"mfaToken"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recoveryCode,omitempty"`
+}
+
+func (r *VerifyMFARequest) Validate() error {
+	if r.MFAToken == "" {
+		return errors.New("MFA token is required")
+	}
+
+	if r.Code == "" && r.RecoveryCode == "" {
+		return errors.New("code or recovery code is required")
+	}
+
+	return nil
+}
+
+type EnrollTOTPRequest struct {
+	Code string `json:"code,omitempty"`
+}
+
+type ConfirmTOTPRequest struct {
+	Code string `json:"code"`
+}
+
+func (r *ConfirmTOTPRequest) Validate() error {
+	if r.Code == "" {
+		return errors.New("code is required")
+	}
+
+	return nil
+}
+
+type RegenerateRecoveryCodesRequest struct {
+	Code string `json:"code"`
+}
+
+func (r *RegenerateRecoveryCodesRequest) Validate() error {
+	if r.Code == "" {
+		return errors.New("code is required")
+	}
+
+	return nil
+}
+
+type DisableTOTPRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+func (r *DisableTOTPRequest) Validate() error {
+	if r.Code == "" {
+		return errors.New("code is required")
+	}
+
+	return nil
+}
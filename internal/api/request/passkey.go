@@ -0,0 +1,37 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+type BeginPasskeyRegistrationRequest struct {
+	Name string `json:"name,omitempty"`
+}
+
+func (r *BeginPasskeyRegistrationRequest) Validate() error {
+	if len(r.Name) > 64 {
+		return errors.New("name must be at most 64 characters long")
+	}
+
+	return nil
+}
+
+type FinishPasskeyCeremonyRequest struct {
+	CeremonyID string          `json:"ceremonyId"`
+	Credential json.RawMessage `json:"credential"`
+}
+
+func (r *FinishPasskeyCeremonyRequest) Validate() error {
+	if _, err := uuid.Parse(r.CeremonyID); err != nil {
+		return errors.New("invalid ceremony ID")
+	}
+
+	if len(r.Credential) == 0 {
+		return errors.New("credential is required")
+	}
+
+	return nil
+}
@@ -58,8 +58,30 @@ func (r *ResetPasswordRequest) Validate() error {
 		return errors.New("passwords do not match")
 	}
 
-	if len(r.Password) < 6 {
-		return errors.New("password must be at least 6 characters")
+	return nil
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+	ConfirmPassword string `json:"confirmPassword"`
+}
+
+func (r *ChangePasswordRequest) Validate() error {
+	if r.CurrentPassword == "" {
+		return errors.New("current password is required")
+	}
+
+	if r.NewPassword == "" {
+		return errors.New("new password is required")
+	}
+
+	if r.NewPassword != r.ConfirmPassword {
+		return errors.New("passwords do not match")
+	}
+
+	if r.NewPassword == r.CurrentPassword {
+		return errors.New("new password must be different from the current password")
 	}
 
 	return nil
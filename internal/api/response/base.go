@@ -98,6 +98,14 @@ func BadRequest(c *fiber.Ctx, message string, err error, errorCode *constants.Er
 	return NewErrorResponse(c, constants.StatusBadRequest, message, err, errorCode).Send(c)
 }
 
+// BadRequestWithData is a BadRequest that also returns details, such as a list
+// of validation failures, in the data field.
+func BadRequestWithData(c *fiber.Ctx, message string, data interface{}, errorCode *constants.ErrorCode) error {
+	response := NewErrorResponse(c, constants.StatusBadRequest, message, nil, errorCode)
+	response.Data = data
+	return response.Send(c)
+}
+
 func Unauthorized(c *fiber.Ctx, message string, err error, errorCode *constants.ErrorCode) error {
 	return NewErrorResponse(c, constants.StatusUnauthorized, message, err, errorCode).Send(c)
 }
@@ -106,6 +114,10 @@ func Forbidden(c *fiber.Ctx, message string, errorCode *constants.ErrorCode) err
 	return NewErrorResponse(c, constants.StatusForbidden, message, nil, errorCode).Send(c)
 }
 
+func TooManyRequests(c *fiber.Ctx, message string, errorCode *constants.ErrorCode) error {
+	return NewErrorResponse(c, constants.StatusTooManyRequests, message, nil, errorCode).Send(c)
+}
+
 func NotFound(c *fiber.Ctx, message string, err error, errorCode *constants.ErrorCode) error {
 	response := NewErrorResponse(c, constants.StatusNotFound, message, err, errorCode)
 	return response.Send(c)
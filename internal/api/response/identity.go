@@ -0,0 +1,37 @@
+package response
+
+import (
+	"time"
+
+	db "cloud-sprint/internal/db/sqlc"
+
+	"github.com/google/uuid"
+)
+
+type IdentityResponse struct {
+	ID             uuid.UUID `json:"id"`
+	Provider       string    `json:"provider"`
+	ProviderUserID string    `json:"provider_user_id"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type IdentityLinkResponse struct {
+	URL string `json:"url"`
+}
+
+func NewIdentityResponse(oauthAccount db.OauthAccount) IdentityResponse {
+	return IdentityResponse{
+		ID:             oauthAccount.ID,
+		Provider:       oauthAccount.Provider,
+		ProviderUserID: oauthAccount.ProviderUserID,
+		CreatedAt:      oauthAccount.CreatedAt,
+	}
+}
+
+func NewIdentitiesResponse(oauthAccounts []db.OauthAccount) []IdentityResponse {
+	res := make([]IdentityResponse, 0, len(oauthAccounts))
+	for _, oauthAccount := range oauthAccounts {
+		res = append(res, NewIdentityResponse(oauthAccount))
+	}
+	return res
+}
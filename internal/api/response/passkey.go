@@ -0,0 +1,41 @@
+package response
+
+import (
+	"time"
+
+	db "cloud-sprint/internal/db/sqlc"
+
+	"github.com/google/uuid"
+)
+
+type PasskeyCeremonyResponse struct {
+	CeremonyID string      `json:"ceremony_id"`
+	Options    interface{} `json:"options"`
+}
+
+type PasskeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func NewPasskeyResponse(credential db.WebauthnCredential) PasskeyResponse {
+	res := PasskeyResponse{
+		ID:        credential.ID,
+		Name:      credential.Name,
+		CreatedAt: credential.CreatedAt,
+	}
+	if credential.LastUsedAt.Valid {
+		res.LastUsedAt = &credential.LastUsedAt.Time
+	}
+	return res
+}
+
+func NewPasskeysResponse(credentials []db.WebauthnCredential) []PasskeyResponse {
+	res := make([]PasskeyResponse, 0, len(credentials))
+	for _, credential := range credentials {
+		res = append(res, NewPasskeyResponse(credential))
+	}
+	return res
+}
@@ -0,0 +1,43 @@
+package response
+
+import "cloud-sprint/internal/service"
+
+type RepositoryResponse struct {
+	Provider    string `json:"provider"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	FullName    string `json:"full_name"`
+	Description string `json:"description"`
+	Private     bool   `json:"private"`
+	URL         string `json:"url"`
+	CloneURL    string `json:"clone_url"`
+	Language    string `json:"language"`
+	Fork        bool   `json:"fork"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+func NewRepositoryResponse(provider string, repo service.Repository) RepositoryResponse {
+	return RepositoryResponse{
+		Provider:    provider,
+		ID:          repo.ID,
+		Name:        repo.Name,
+		FullName:    repo.FullName,
+		Description: repo.Description,
+		Private:     repo.Private,
+		URL:         repo.HTMLURL,
+		CloneURL:    repo.CloneURL,
+		Language:    repo.Language,
+		Fork:        repo.Fork,
+		CreatedAt:   repo.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:   repo.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+func NewRepositoriesResponse(provider string, repos []service.Repository) []RepositoryResponse {
+	response := make([]RepositoryResponse, len(repos))
+	for i, repo := range repos {
+		response[i] = NewRepositoryResponse(provider, repo)
+	}
+	return response
+}
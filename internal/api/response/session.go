@@ -0,0 +1,39 @@
+package response
+
+import (
+	"time"
+
+	db "cloud-sprint/internal/db/sqlc"
+
+	"github.com/google/uuid"
+)
+
+type SessionResponse struct {
+	ID        uuid.UUID `json:"id"`
+	UserAgent string    `json:"user_agent"`
+	ClientIP  string    `json:"client_ip"`
+	IsBlocked bool      `json:"is_blocked"`
+	IsCurrent bool      `json:"is_current"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewSessionResponse(session db.Session, currentSessionID string) SessionResponse {
+	return SessionResponse{
+		ID:        session.ID,
+		UserAgent: session.UserAgent,
+		ClientIP:  session.ClientIp,
+		IsBlocked: session.IsBlocked,
+		IsCurrent: session.ID.String() == currentSessionID,
+		ExpiresAt: session.ExpiresAt,
+		CreatedAt: session.CreatedAt,
+	}
+}
+
+func NewSessionsResponse(sessions []db.Session, currentSessionID string) []SessionResponse {
+	response := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		response[i] = NewSessionResponse(session, currentSessionID)
+	}
+	return response
+}
@@ -1,46 +1,167 @@
 package router
 
 import (
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 
 	"cloud-sprint/config"
 	"cloud-sprint/internal/api/handler"
-	db "cloud-sprint/internal/db/sqlc"
+	"cloud-sprint/internal/api/middleware"
+	"cloud-sprint/internal/db"
 	"cloud-sprint/internal/service"
 	"cloud-sprint/internal/token"
 )
 
-func SetupAuthRoutes(api fiber.Router, store db.Querier, tokenMaker token.Maker, config config.Config, authMiddleware fiber.Handler, refreshMiddleware fiber.Handler) {
+func SetupAuthRoutes(api fiber.Router, store db.Store, tokenMaker token.Maker, denylist token.Denylist, rateLimitStore middleware.RateLimitStore, webAuthnService *service.WebAuthnService, oauthProviders *service.OAuthProviderRegistry, config config.Config, authMiddleware fiber.Handler, refreshMiddleware fiber.Handler) {
 	emailService := service.NewEmailService(config.Email)
-	googleService := service.NewGoogleService(config)
-	githubService := service.NewGitHubService(config)
+	totpService := service.NewTOTPService(config)
+
+	signUpLimiter := middleware.NewRateLimiter(rateLimitStore, middleware.RateLimitPolicy{
+		Name: "sign-up",
+		Rules: []middleware.RateLimitRule{
+			{KeyBy: middleware.RateLimitByIP, Limit: 10, Window: time.Hour},
+		},
+	})
+	signInLimiter := middleware.NewRateLimiter(rateLimitStore, middleware.RateLimitPolicy{
+		Name: "sign-in",
+		Rules: []middleware.RateLimitRule{
+			{KeyBy: middleware.RateLimitByIP, Limit: 20, Window: 15 * time.Minute},
+			{KeyBy: middleware.RateLimitByEmail, Limit: 10, Window: 15 * time.Minute},
+		},
+	})
+	forgotPasswordLimiter := middleware.NewRateLimiter(rateLimitStore, middleware.RateLimitPolicy{
+		Name: "forgot-password",
+		Rules: []middleware.RateLimitRule{
+			{KeyBy: middleware.RateLimitByIP, Limit: 10, Window: time.Hour},
+			{KeyBy: middleware.RateLimitByEmail, Limit: 3, Window: time.Hour},
+		},
+	})
+	sendOTPLimiter := middleware.NewRateLimiter(rateLimitStore, middleware.RateLimitPolicy{
+		Name: "send-otp",
+		Rules: []middleware.RateLimitRule{
+			{KeyBy: middleware.RateLimitByIP, Limit: 10, Window: time.Hour},
+			{KeyBy: middleware.RateLimitByEmail, Limit: 3, Window: 15 * time.Minute},
+		},
+	})
+	verifyMFALimiter := middleware.NewRateLimiter(rateLimitStore, middleware.RateLimitPolicy{
+		Name: "verify-mfa",
+		Rules: []middleware.RateLimitRule{
+			{KeyBy: middleware.RateLimitByIP, Limit: 20, Window: 15 * time.Minute},
+			{KeyBy: middleware.RateLimitByMFAToken, Limit: 5, Window: 15 * time.Minute},
+		},
+	})
+	resetPasswordLimiter := middleware.NewRateLimiter(rateLimitStore, middleware.RateLimitPolicy{
+		Name: "reset-password",
+		Rules: []middleware.RateLimitRule{
+			{KeyBy: middleware.RateLimitByIP, Limit: 10, Window: 15 * time.Minute},
+			{KeyBy: middleware.RateLimitByEmail, Limit: 5, Window: 15 * time.Minute},
+		},
+	})
 
-	authHandler := handler.NewAuthHandler(store, tokenMaker, config, emailService)
+	// The limiters below are keyed by user and mounted after authMiddleware.
+	changePasswordLimiter := middleware.NewRateLimiter(rateLimitStore, middleware.RateLimitPolicy{
+		Name: "change-password",
+		Rules: []middleware.RateLimitRule{
+			{KeyBy: middleware.RateLimitByIP, Limit: 20, Window: 15 * time.Minute},
+			{KeyBy: middleware.RateLimitByUserID, Limit: 5, Window: 15 * time.Minute},
+		},
+	})
+	manageMFALimiter := middleware.NewRateLimiter(rateLimitStore, middleware.RateLimitPolicy{
+		Name: "manage-mfa",
+		Rules: []middleware.RateLimitRule{
+			{KeyBy: middleware.RateLimitByUserID, Limit: 10, Window: 15 * time.Minute},
+		},
+	})
+	magicLinkLimiter := middleware.NewRateLimiter(rateLimitStore, middleware.RateLimitPolicy{
+		Name: "magic-link",
+		Rules: []middleware.RateLimitRule{
+			{KeyBy: middleware.RateLimitByIP, Limit: 10, Window: time.Hour},
+			{KeyBy: middleware.RateLimitByEmail, Limit: 3, Window: time.Hour},
+		},
+	})
+	passkeyLoginLimiter := middleware.NewRateLimiter(rateLimitStore, middleware.RateLimitPolicy{
+		Name: "passkey-login",
+		Rules: []middleware.RateLimitRule{
+			{KeyBy: middleware.RateLimitByIP, Limit: 30, Window: 15 * time.Minute},
+		},
+	})
+	consumeMagicLinkLimiter := middleware.NewRateLimiter(rateLimitStore, middleware.RateLimitPolicy{
+		Name: "consume-magic-link",
+		Rules: []middleware.RateLimitRule{
+			{KeyBy: middleware.RateLimitByIP, Limit: 30, Window: 15 * time.Minute},
+			{KeyBy: middleware.RateLimitByEmail, Limit: 10, Window: 15 * time.Minute},
+		},
+	})
+	verifyResetTokenLimiter := middleware.NewRateLimiter(rateLimitStore, middleware.RateLimitPolicy{
+		Name: "verify-reset-token",
+		Rules: []middleware.RateLimitRule{
+			{KeyBy: middleware.RateLimitByIP, Limit: 30, Window: 15 * time.Minute},
+			{KeyBy: middleware.RateLimitByEmail, Limit: 10, Window: 15 * time.Minute},
+		},
+	})
+	verifyEmailLimiter := middleware.NewRateLimiter(rateLimitStore, middleware.RateLimitPolicy{
+		Name: "verify-email",
+		Rules: []middleware.RateLimitRule{
+			{KeyBy: middleware.RateLimitByIP, Limit: 30, Window: 15 * time.Minute},
+			{KeyBy: middleware.RateLimitByEmail, Limit: 10, Window: 15 * time.Minute},
+		},
+	})
+
+	authHandler := handler.NewAuthHandler(store, tokenMaker, denylist, config, emailService, totpService)
 
 	auth := api.Group("/auth")
-	auth.Post("/sign-up", authHandler.SignUp)
-	auth.Post("/sign-in", authHandler.SignIn)
+	auth.Post("/sign-up", signUpLimiter, authHandler.SignUp)
+	auth.Post("/sign-in", signInLimiter, authHandler.SignIn)
 	auth.Post("/refresh", refreshMiddleware, authHandler.RefreshToken)
+	auth.Post("/sign-out", authMiddleware, authHandler.SignOut)
 	auth.Get("/me", authMiddleware, authHandler.Me)
 
+	mfaHandler := handler.NewMFAHandler(store, config, totpService)
+	mfa := auth.Group("/mfa")
+	mfa.Post("/verify", verifyMFALimiter, authHandler.VerifyMFA)
+	mfa.Post("/totp/enroll", authMiddleware, manageMFALimiter, mfaHandler.EnrollTOTP)
+	mfa.Post("/totp/confirm", authMiddleware, manageMFALimiter, mfaHandler.ConfirmTOTP)
+	mfa.Post("/totp/disable", authMiddleware, manageMFALimiter, mfaHandler.DisableTOTP)
+	mfa.Post("/recovery-codes", authMiddleware, manageMFALimiter, mfaHandler.RegenerateRecoveryCodes)
+
+	passkeyHandler := handler.NewPasskeyHandler(store, tokenMaker, config, webAuthnService)
+	passkeys := auth.Group("/passkeys")
+	passkeys.Post("/register/begin", authMiddleware, passkeyHandler.BeginRegistration)
+	passkeys.Post("/register/finish", authMiddleware, passkeyHandler.FinishRegistration)
+	passkeys.Post("/login/begin", passkeyLoginLimiter, passkeyHandler.BeginLogin)
+	passkeys.Post("/login/finish", passkeyLoginLimiter, passkeyHandler.FinishLogin)
+	passkeys.Get("/", authMiddleware, passkeyHandler.ListPasskeys)
+	passkeys.Delete("/:id", authMiddleware, passkeyHandler.DeletePasskey)
+
+	sessionHandler := handler.NewSessionHandler(store, tokenMaker, config)
+	auth.Get("/sessions", authMiddleware, sessionHandler.ListSessions)
+	auth.Post("/sessions/revoke-others", authMiddleware, sessionHandler.RevokeOtherSessions)
+	auth.Delete("/sessions/:id", authMiddleware, sessionHandler.RevokeSession)
+
+	magicLinkHandler := handler.NewMagicLinkHandler(store, tokenMaker, config, emailService)
+	auth.Post("/magic-link", magicLinkLimiter, magicLinkHandler.RequestMagicLink)
+	auth.Post("/magic-link/consume", consumeMagicLinkLimiter, magicLinkHandler.ConsumeMagicLink)
+
 	passwordHandler := handler.NewPasswordHandler(store, tokenMaker, config, emailService)
-	auth.Post("/forgot-password", passwordHandler.ForgotPassword)
-	auth.Post("/verify-reset-token", passwordHandler.VerifyResetToken)
-	auth.Post("/reset-password", passwordHandler.ResetPassword)
+	auth.Post("/forgot-password", forgotPasswordLimiter, passwordHandler.ForgotPassword)
+	auth.Post("/verify-reset-token", verifyResetTokenLimiter, passwordHandler.VerifyResetToken)
+	auth.Post("/reset-password", resetPasswordLimiter, passwordHandler.ResetPassword)
+	auth.Post("/change-password", authMiddleware, changePasswordLimiter, passwordHandler.ChangePassword)
 
 	emailVerificationHandler := handler.NewEmailVerificationHandler(store, config, tokenMaker, emailService)
 	verifyEmail := auth.Group("/verify-email")
-	verifyEmail.Post("/send-otp", emailVerificationHandler.SendOTP)
-	verifyEmail.Post("/verify", emailVerificationHandler.VerifyOTP)
+	verifyEmail.Post("/send-otp", sendOTPLimiter, emailVerificationHandler.SendOTP)
+	verifyEmail.Post("/verify", verifyEmailLimiter, emailVerificationHandler.VerifyOTP)
 	verifyEmail.Get("/status", emailVerificationHandler.CheckVerificationStatus)
 
-	googleAuthHandler := handler.NewGoogleAuthHandler(store, tokenMaker, config, emailService, googleService)
-	googleAuth := auth.Group("/google")
-	googleAuth.Get("/auth", googleAuthHandler.GoogleAuth)
-	googleAuth.Get("/callback", googleAuthHandler.GoogleCallback)
+	identityHandler := handler.NewIdentityHandler(store, config, oauthProviders)
+	identities := auth.Group("/identities")
+	identities.Get("/", authMiddleware, identityHandler.ListIdentities)
+	identities.Post("/:provider", authMiddleware, identityHandler.LinkIdentity)
+	identities.Delete("/:provider", authMiddleware, identityHandler.UnlinkIdentity)
 
-	githubAuthHandler := handler.NewGitHubAuthHandler(store, tokenMaker, config, emailService, githubService)
-	githubAuth := auth.Group("/github")
-	githubAuth.Get("/auth", githubAuthHandler.GitHubAuth)
-	githubAuth.Get("/callback", githubAuthHandler.GitHubCallback)
+	oauthHandler := handler.NewOAuthHandler(store, tokenMaker, config, oauthProviders)
+	auth.Get("/:provider/auth", oauthHandler.Authorize)
+	auth.Get("/:provider/callback", oauthHandler.Callback)
 }
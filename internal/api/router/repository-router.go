@@ -0,0 +1,18 @@
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"cloud-sprint/config"
+	"cloud-sprint/internal/api/handler"
+	db "cloud-sprint/internal/db/sqlc"
+	"cloud-sprint/internal/service"
+)
+
+func SetupRepositoryRoutes(api fiber.Router, store db.Querier, oauthProviders *service.OAuthProviderRegistry, config config.Config, authMiddleware fiber.Handler) {
+	repositoryHandler := handler.NewRepositoryHandler(store, config, oauthProviders)
+
+	repositories := api.Group("/repositories")
+	repositories.Get("/", authMiddleware, repositoryHandler.ListRepositories)
+	repositories.Get("/:provider", authMiddleware, repositoryHandler.ListProviderRepositories)
+}
@@ -5,13 +5,18 @@ import (
 	"go.uber.org/zap"
 
 	"cloud-sprint/config"
-	db "cloud-sprint/internal/db/sqlc"
+	"cloud-sprint/internal/api/middleware"
+	"cloud-sprint/internal/db"
+	"cloud-sprint/internal/service"
 	"cloud-sprint/internal/token"
 )
 
-func SetupRoutes(app *fiber.App, store db.Querier, tokenMaker token.Maker, logger *zap.Logger, config config.Config, authMiddleware fiber.Handler, refreshMiddleware fiber.Handler) {
+func SetupRoutes(app *fiber.App, store db.Store, tokenMaker token.Maker, denylist token.Denylist, rateLimitStore middleware.RateLimitStore, webAuthnService *service.WebAuthnService, oauthProviders *service.OAuthProviderRegistry, logger *zap.Logger, config config.Config, authMiddleware fiber.Handler, refreshMiddleware fiber.Handler) {
+	SetupWellKnownRoutes(app, tokenMaker)
+
 	api := app.Group("/api/v1")
 
-	SetupAuthRoutes(api, store, tokenMaker, config, authMiddleware, refreshMiddleware)
+	SetupAuthRoutes(api, store, tokenMaker, denylist, rateLimitStore, webAuthnService, oauthProviders, config, authMiddleware, refreshMiddleware)
 	SetupGitHubRoutes(api, store, tokenMaker, config, authMiddleware)
+	SetupRepositoryRoutes(api, store, oauthProviders, config, authMiddleware)
 }
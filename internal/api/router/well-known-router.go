@@ -0,0 +1,15 @@
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"cloud-sprint/internal/api/handler"
+	"cloud-sprint/internal/token"
+)
+
+func SetupWellKnownRoutes(app *fiber.App, tokenMaker token.Maker) {
+	jwksHandler := handler.NewJWKSHandler(tokenMaker)
+
+	wellKnown := app.Group("/.well-known")
+	wellKnown.Get("/jwks.json", jwksHandler.GetJWKS)
+}
@@ -1,7 +1,9 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
@@ -11,36 +13,55 @@ import (
 	"cloud-sprint/config"
 	"cloud-sprint/internal/api/middleware"
 	"cloud-sprint/internal/api/router"
-	db "cloud-sprint/internal/db/sqlc"
+	"cloud-sprint/internal/db"
+	"cloud-sprint/internal/service"
 	"cloud-sprint/internal/token"
 
 	_ "cloud-sprint/docs/swagger"
 )
 
+const oidcDiscoveryTimeout = 10 * time.Second
+
 type Server struct {
 	app  *fiber.App
 	log  *zap.Logger
 	port string
 }
 
-func New(store db.Querier, cfg config.Config, log *zap.Logger) (*Server, error) {
-	tokenMaker, err := token.NewJWTMaker(cfg.JWT.SecretKey, cfg.JWT.RefreshSecretKey)
+func New(store db.Store, cfg config.Config, log *zap.Logger) (*Server, error) {
+	tokenMaker, err := newTokenMaker(cfg.JWT)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create token maker: %w", err)
 	}
 
+	webAuthnService, err := service.NewWebAuthnService(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create WebAuthn service: %w", err)
+	}
+
+	discoveryCtx, cancel := context.WithTimeout(context.Background(), oidcDiscoveryTimeout)
+	defer cancel()
+
+	oauthProviders, err := service.NewOAuthProviderRegistryFromConfig(discoveryCtx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OAuth providers: %w", err)
+	}
+
+	denylist := newDenylist(store, cfg.Security)
+	rateLimitStore := middleware.NewMemoryRateLimitStore()
+
 	app := fiber.New(fiber.Config{})
 
 	app.Use(recover.New())
 	app.Use(middleware.CORS())
 
-	authMiddleware := middleware.NewAuthMiddleware(tokenMaker, "Authorization", store, cfg)
-	refreshMiddleware := middleware.NewAuthMiddleware(tokenMaker, "Refresh", store, cfg)
+	authMiddleware := middleware.NewAuthMiddleware(tokenMaker, "Authorization", store, denylist, cfg)
+	refreshMiddleware := middleware.NewAuthMiddleware(tokenMaker, "refresh", store, denylist, cfg)
 
 	loggerMiddleware := middleware.NewLogger(log)
 	app.Use(loggerMiddleware)
 
-	router.SetupRoutes(app, store, tokenMaker, log, cfg, authMiddleware, refreshMiddleware)
+	router.SetupRoutes(app, store, tokenMaker, denylist, rateLimitStore, webAuthnService, oauthProviders, log, cfg, authMiddleware, refreshMiddleware)
 
 	app.Get("/swagger/*", swagger.HandlerDefault)
 
@@ -51,6 +72,61 @@ func New(store db.Querier, cfg config.Config, log *zap.Logger) (*Server, error)
 	}, nil
 }
 
+// newDenylist defaults to the database so revoked tokens stay revoked across
+// restarts and instances.
+func newDenylist(store db.Store, cfg config.SecurityConfig) token.Denylist {
+	if cfg.DenylistStore == token.DenylistMemory {
+		return token.NewMemoryDenylist()
+	}
+	return db.NewSQLDenylist(store)
+}
+
+func newTokenMaker(cfg config.JWTConfig) (token.Maker, error) {
+	switch cfg.Format {
+	case token.FormatPasetoLocal:
+		return token.NewPasetoLocalMaker(cfg.PasetoLocalKey, cfg.PasetoRefreshLocalKey)
+	case token.FormatPasetoPublic:
+		return token.NewPasetoPublicMaker(cfg.PrivateKeyPath)
+	case token.FormatJWT, "":
+		return newJWTMaker(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported token format %q", cfg.Format)
+	}
+}
+
+func newJWTMaker(cfg config.JWTConfig) (token.Maker, error) {
+	switch cfg.Algorithm {
+	case token.AlgorithmRS256, token.AlgorithmEdDSA:
+		keys, err := token.NewAsymmetricKeyring(cfg.KeyID, cfg.Algorithm, cfg.PrivateKeyPath, cfg.VerificationKeyPaths)
+		if err != nil {
+			return nil, err
+		}
+
+		// Refresh tokens stay on a shared secret so the published key set
+		// only ever verifies access tokens.
+		internalKeys, err := token.NewHMACKeyring("", cfg.RefreshSecretKey, cfg.RefreshVerificationSecretKeys)
+		if err != nil {
+			return nil, fmt.Errorf("invalid refresh token key: %w", err)
+		}
+
+		return token.NewAsymmetricJWTMaker(keys, internalKeys)
+	case token.AlgorithmHS256, "":
+		accessKeys, err := token.NewHMACKeyring(cfg.KeyID, cfg.SecretKey, cfg.VerificationSecretKeys)
+		if err != nil {
+			return nil, fmt.Errorf("invalid access token key: %w", err)
+		}
+
+		refreshKeys, err := token.NewHMACKeyring(cfg.KeyID, cfg.RefreshSecretKey, cfg.RefreshVerificationSecretKeys)
+		if err != nil {
+			return nil, fmt.Errorf("invalid refresh token key: %w", err)
+		}
+
+		return token.NewJWTMakerWithKeyrings(accessKeys, refreshKeys)
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", cfg.Algorithm)
+	}
+}
+
 func (s *Server) Start(port string) error {
 	return s.app.Listen(fmt.Sprintf(":%s", port))
 }
@@ -3,6 +3,12 @@ package constants
 type ErrorCode string
 
 const (
-	COMMON_ERROR     ErrorCode = "000001"
-	EMAIL_UNVERIFIED ErrorCode = "000002"
+	COMMON_ERROR          ErrorCode = "000001"
+	EMAIL_UNVERIFIED      ErrorCode = "000002"
+	ACCOUNT_LOCKED        ErrorCode = "000003"
+	RATE_LIMITED          ErrorCode = "000004"
+	OTP_ATTEMPTS_EXCEEDED ErrorCode = "000005"
+	OTP_RESEND_COOLDOWN   ErrorCode = "000006"
+	WEAK_PASSWORD         ErrorCode = "000007"
+	IDENTITY_NOT_LINKED   ErrorCode = "000008"
 )
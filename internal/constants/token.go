@@ -3,6 +3,8 @@ package constants
 type TokenType string
 
 const (
-	AccessTokenType  TokenType = "access"
-	RefreshTokenType TokenType = "refresh"
+	AccessTokenType    TokenType = "access"
+	RefreshTokenType   TokenType = "refresh"
+	MFATokenType       TokenType = "mfa"
+	MagicLinkTokenType TokenType = "magic_link"
 )
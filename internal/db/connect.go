@@ -8,10 +8,9 @@ import (
 	"go.uber.org/zap"
 
 	"cloud-sprint/config"
-	sqlc "cloud-sprint/internal/db/sqlc"
 )
 
-func Connect(dbConfig config.DBConfig, log *zap.Logger) (*sql.DB, sqlc.Querier, error) {
+func Connect(dbConfig config.DBConfig, log *zap.Logger) (*sql.DB, Store, error) {
 	conn, err := sql.Open(dbConfig.Driver, dbConfig.Source)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to open database connection: %w", err)
@@ -29,7 +28,5 @@ func Connect(dbConfig config.DBConfig, log *zap.Logger) (*sql.DB, sqlc.Querier,
 
 	log.Info("database connected successfully")
 
-	queries := sqlc.New(conn)
-
-	return conn, queries, nil
+	return conn, NewStore(conn), nil
 }
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"context"
+	"log"
+	"time"
+
+	sqlc "cloud-sprint/internal/db/sqlc"
+)
+
+// SQLDenylist stores revoked token and session IDs in the revoked_tokens
+// table so revocations survive restarts and are shared between instances.
+type SQLDenylist struct {
+	queries sqlc.Querier
+}
+
+func NewSQLDenylist(queries sqlc.Querier) *SQLDenylist {
+	return &SQLDenylist{
+		queries: queries,
+	}
+}
+
+func (d *SQLDenylist) Add(ctx context.Context, id string, expiresAt time.Time) error {
+	if err := d.queries.DeleteExpiredRevokedTokens(ctx); err != nil {
+		log.Printf("Failed to delete expired revoked tokens: %v", err)
+	}
+
+	return d.queries.RevokeToken(ctx, sqlc.RevokeTokenParams{
+		ID:        id,
+		ExpiresAt: expiresAt,
+	})
+}
+
+func (d *SQLDenylist) Contains(ctx context.Context, id string) (bool, error) {
+	return d.queries.IsTokenRevoked(ctx, id)
+}
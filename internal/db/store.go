@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	sqlc "cloud-sprint/internal/db/sqlc"
+)
+
+// Store adds transactions on top of the generated queries.
+type Store interface {
+	sqlc.Querier
+	ExecTx(ctx context.Context, fn func(sqlc.Querier) error) error
+}
+
+type SQLStore struct {
+	*sqlc.Queries
+	conn *sql.DB
+}
+
+func NewStore(conn *sql.DB) Store {
+	return &SQLStore{
+		Queries: sqlc.New(conn),
+		conn:    conn,
+	}
+}
+
+// ExecTx runs fn inside a transaction and rolls it back if fn returns an error.
+func (store *SQLStore) ExecTx(ctx context.Context, fn func(sqlc.Querier) error) error {
+	tx, err := store.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(store.Queries.WithTx(tx)); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("tx error: %v, rollback error: %w", err, rollbackErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
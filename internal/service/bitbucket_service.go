@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"cloud-sprint/config"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/bitbucket"
+)
+
+const bitbucketAPIURL = "https://api.bitbucket.org/2.0"
+
+type BitbucketUserInfo struct {
+	UUID        string `json:"uuid"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	Email       string `json:"-"`
+}
+
+type BitbucketRepository struct {
+	UUID        string `json:"uuid"`
+	Name        string `json:"name"`
+	FullName    string `json:"full_name"`
+	Description string `json:"description"`
+	IsPrivate   bool   `json:"is_private"`
+	Language    string `json:"language"`
+	Links       struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+		Clone []struct {
+			Name string `json:"name"`
+			Href string `json:"href"`
+		} `json:"clone"`
+	} `json:"links"`
+	Parent    json.RawMessage `json:"parent"`
+	CreatedOn time.Time       `json:"created_on"`
+	UpdatedOn time.Time       `json:"updated_on"`
+}
+
+type BitbucketService struct {
+	config config.Config
+}
+
+func NewBitbucketService(config config.Config) *BitbucketService {
+	return &BitbucketService{
+		config: config,
+	}
+}
+
+func (s *BitbucketService) GetOAuthConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     s.config.OAuth.BitbucketClientID,
+		ClientSecret: s.config.OAuth.BitbucketClientSecret,
+		RedirectURL:  s.config.OAuth.BitbucketRedirectURL,
+		Scopes:       []string{"account", "email", "repository"},
+		Endpoint:     bitbucket.Endpoint,
+	}
+}
+
+func (s *BitbucketService) Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	oauthConfig := s.GetOAuthConfig()
+	return oauthConfig.Exchange(ctx, code, opts...)
+}
+
+// GetUserInfo returns the user with their primary confirmed email. Email is
+// left empty when the user has no confirmed address.
+func (s *BitbucketService) GetUserInfo(token *oauth2.Token) (*BitbucketUserInfo, error) {
+	client := oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(token))
+
+	var userInfo BitbucketUserInfo
+	if err := getBitbucketJSON(client, bitbucketAPIURL+"/user", &userInfo); err != nil {
+		return nil, fmt.Errorf("failed to get user info: %w", err)
+	}
+
+	var emails struct {
+		Values []struct {
+			Email       string `json:"email"`
+			IsPrimary   bool   `json:"is_primary"`
+			IsConfirmed bool   `json:"is_confirmed"`
+		} `json:"values"`
+	}
+	if err := getBitbucketJSON(client, bitbucketAPIURL+"/user/emails", &emails); err != nil {
+		return nil, fmt.Errorf("failed to get user emails: %w", err)
+	}
+
+	for _, email := range emails.Values {
+		if email.IsPrimary && email.IsConfirmed {
+			userInfo.Email = email.Email
+			break
+		}
+	}
+
+	return &userInfo, nil
+}
+
+// GetUserRepositories returns every repository the user is a member of.
+func (s *BitbucketService) GetUserRepositories(token *oauth2.Token) ([]BitbucketRepository, error) {
+	client := oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(token))
+
+	repos := []BitbucketRepository{}
+	url := bitbucketAPIURL + "/repositories?role=member&pagelen=100&sort=-updated_on"
+
+	for url != "" {
+		var page struct {
+			Values []BitbucketRepository `json:"values"`
+			Next   string                `json:"next"`
+		}
+		if err := getBitbucketJSON(client, url, &page); err != nil {
+			return nil, fmt.Errorf("failed to get repositories: %w", err)
+		}
+
+		repos = append(repos, page.Values...)
+		url = page.Next
+	}
+
+	return repos, nil
+}
+
+func getBitbucketJSON(client *http.Client, url string, target interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Bitbucket API returned non-200 status: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	return json.Unmarshal(body, target)
+}
+
+type bitbucketProvider struct {
+	service *BitbucketService
+}
+
+func NewBitbucketProvider(service *BitbucketService) RepositoryProvider {
+	return &bitbucketProvider{service: service}
+}
+
+func (p *bitbucketProvider) Name() string {
+	return "bitbucket"
+}
+
+func (p *bitbucketProvider) OAuthConfig() *oauth2.Config {
+	return p.service.GetOAuthConfig()
+}
+
+func (p *bitbucketProvider) AuthCodeOptions() []oauth2.AuthCodeOption {
+	return nil
+}
+
+func (p *bitbucketProvider) Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	return p.service.Exchange(ctx, code, opts...)
+}
+
+func (p *bitbucketProvider) GetUserInfo(ctx context.Context, token *oauth2.Token, nonce string) (*OAuthUserInfo, error) {
+	userInfo, err := p.service.GetUserInfo(token)
+	if err != nil {
+		return nil, err
+	}
+
+	firstName, lastName := parseFullName(userInfo.DisplayName)
+	if firstName == "" {
+		firstName = userInfo.Username
+	}
+
+	return &OAuthUserInfo{
+		ProviderUserID: userInfo.UUID,
+		Email:          userInfo.Email,
+		EmailVerified:  userInfo.Email != "",
+		FirstName:      firstName,
+		LastName:       lastName,
+	}, nil
+}
+
+func (p *bitbucketProvider) GetUserRepositories(ctx context.Context, token *oauth2.Token) ([]Repository, error) {
+	bitbucketRepos, err := p.service.GetUserRepositories(token)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make([]Repository, len(bitbucketRepos))
+	for i, repo := range bitbucketRepos {
+		cloneURL := ""
+		for _, link := range repo.Links.Clone {
+			if link.Name == "https" {
+				cloneURL = link.Href
+			}
+		}
+
+		repos[i] = Repository{
+			ID:          repo.UUID,
+			Name:        repo.Name,
+			FullName:    repo.FullName,
+			Description: repo.Description,
+			Private:     repo.IsPrivate,
+			HTMLURL:     repo.Links.HTML.Href,
+			CloneURL:    cloneURL,
+			Language:    repo.Language,
+			Fork:        len(repo.Parent) > 0 && string(repo.Parent) != "null",
+			CreatedAt:   repo.CreatedOn,
+			UpdatedAt:   repo.UpdatedOn,
+		}
+	}
+
+	return repos, nil
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"cloud-sprint/config"
@@ -56,9 +58,9 @@ func (s *GitHubService) GetOAuthConfig() *oauth2.Config {
 	}
 }
 
-func (s *GitHubService) Exchange(ctx context.Context, code string) (*oauth2.Token, error) {
+func (s *GitHubService) Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
 	oauthConfig := s.GetOAuthConfig()
-	return oauthConfig.Exchange(ctx, code)
+	return oauthConfig.Exchange(ctx, code, opts...)
 }
 
 func (s *GitHubService) GetUserInfo(token *oauth2.Token) (*GitHubUserInfo, error) {
@@ -194,3 +196,88 @@ func (s *GitHubService) getPrimaryEmail(client *http.Client) (string, error) {
 func containsNextPage(linkHeader string) bool {
 	return len(linkHeader) > 0 && linkHeader != "" && linkHeader != "undefined"
 }
+
+type githubProvider struct {
+	service *GitHubService
+}
+
+func NewGitHubProvider(service *GitHubService) RepositoryProvider {
+	return &githubProvider{service: service}
+}
+
+func (p *githubProvider) Name() string {
+	return "github"
+}
+
+func (p *githubProvider) OAuthConfig() *oauth2.Config {
+	return p.service.GetOAuthConfig()
+}
+
+func (p *githubProvider) AuthCodeOptions() []oauth2.AuthCodeOption {
+	return []oauth2.AuthCodeOption{oauth2.AccessTypeOnline}
+}
+
+func (p *githubProvider) Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	return p.service.Exchange(ctx, code, opts...)
+}
+
+// GetUserInfo only reports emails GitHub has verified: the profile email must
+// be a verified address and the fallback lookup skips unverified ones.
+func (p *githubProvider) GetUserInfo(ctx context.Context, token *oauth2.Token, nonce string) (*OAuthUserInfo, error) {
+	userInfo, err := p.service.GetUserInfo(token)
+	if err != nil {
+		return nil, err
+	}
+
+	firstName, lastName := parseFullName(userInfo.Name)
+	if firstName == "" {
+		firstName = userInfo.Login
+	}
+
+	return &OAuthUserInfo{
+		ProviderUserID: strconv.Itoa(userInfo.ID),
+		Email:          userInfo.Email,
+		EmailVerified:  userInfo.Email != "",
+		FirstName:      firstName,
+		LastName:       lastName,
+	}, nil
+}
+
+func (p *githubProvider) GetUserRepositories(ctx context.Context, token *oauth2.Token) ([]Repository, error) {
+	githubRepos, err := p.service.GetUserRepositories(token)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make([]Repository, len(githubRepos))
+	for i, repo := range githubRepos {
+		repos[i] = Repository{
+			ID:          strconv.Itoa(repo.ID),
+			Name:        repo.Name,
+			FullName:    repo.FullName,
+			Description: repo.Description,
+			Private:     repo.Private,
+			HTMLURL:     repo.HTMLURL,
+			CloneURL:    repo.CloneURL,
+			Language:    repo.Language,
+			Fork:        repo.Fork,
+			CreatedAt:   repo.CreatedAt,
+			UpdatedAt:   repo.UpdatedAt,
+		}
+	}
+
+	return repos, nil
+}
+
+func parseFullName(fullName string) (string, string) {
+	if fullName == "" {
+		return "", ""
+	}
+
+	parts := strings.Split(fullName, " ")
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+
+	return parts[0], parts[len(parts)-1]
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cloud-sprint/config"
+
+	"golang.org/x/oauth2"
+)
+
+type GitLabUserInfo struct {
+	ID          int        `json:"id"`
+	Username    string     `json:"username"`
+	Name        string     `json:"name"`
+	Email       string     `json:"email"`
+	AvatarURL   string     `json:"avatar_url"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+}
+
+type GitLabProject struct {
+	ID                int             `json:"id"`
+	Name              string          `json:"name"`
+	PathWithNamespace string          `json:"path_with_namespace"`
+	Description       string          `json:"description"`
+	Visibility        string          `json:"visibility"`
+	WebURL            string          `json:"web_url"`
+	HTTPURLToRepo     string          `json:"http_url_to_repo"`
+	ForkedFromProject json.RawMessage `json:"forked_from_project"`
+	CreatedAt         time.Time       `json:"created_at"`
+	LastActivityAt    time.Time       `json:"last_activity_at"`
+}
+
+type GitLabService struct {
+	config config.Config
+}
+
+func NewGitLabService(config config.Config) *GitLabService {
+	return &GitLabService{
+		config: config,
+	}
+}
+
+func (s *GitLabService) GetOAuthConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     s.config.OAuth.GitLabClientID,
+		ClientSecret: s.config.OAuth.GitLabClientSecret,
+		RedirectURL:  s.config.OAuth.GitLabRedirectURL,
+		Scopes:       []string{"read_user", "read_api"},
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  s.config.OAuth.GitLabBaseURL + "/oauth/authorize",
+			TokenURL: s.config.OAuth.GitLabBaseURL + "/oauth/token",
+		},
+	}
+}
+
+func (s *GitLabService) Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	oauthConfig := s.GetOAuthConfig()
+	return oauthConfig.Exchange(ctx, code, opts...)
+}
+
+func (s *GitLabService) GetUserInfo(token *oauth2.Token) (*GitLabUserInfo, error) {
+	client := oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(token))
+
+	resp, err := client.Get(s.config.OAuth.GitLabBaseURL + "/api/v4/user")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user info: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitLab API returned non-200 status: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var userInfo GitLabUserInfo
+	if err := json.Unmarshal(body, &userInfo); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user info: %w", err)
+	}
+
+	return &userInfo, nil
+}
+
+// GetUserRepositories returns every project the user is a member of.
+func (s *GitLabService) GetUserRepositories(token *oauth2.Token) ([]GitLabProject, error) {
+	client := oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(token))
+
+	projects := []GitLabProject{}
+	page := "1"
+	const perPage = 100
+
+	for page != "" {
+		url := fmt.Sprintf("%s/api/v4/projects?membership=true&order_by=last_activity_at&page=%s&per_page=%d", s.config.OAuth.GitLabBaseURL, page, perPage)
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get projects: %w", err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			return nil, fmt.Errorf("failed to close response body: %w", closeErr)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("GitLab API returned non-200 status: %d", resp.StatusCode)
+		}
+
+		var pageProjects []GitLabProject
+		if err := json.Unmarshal(body, &pageProjects); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal projects: %w", err)
+		}
+
+		projects = append(projects, pageProjects...)
+
+		page = resp.Header.Get("X-Next-Page")
+	}
+
+	return projects, nil
+}
+
+type gitlabProvider struct {
+	service *GitLabService
+}
+
+func NewGitLabProvider(service *GitLabService) RepositoryProvider {
+	return &gitlabProvider{service: service}
+}
+
+func (p *gitlabProvider) Name() string {
+	return "gitlab"
+}
+
+func (p *gitlabProvider) OAuthConfig() *oauth2.Config {
+	return p.service.GetOAuthConfig()
+}
+
+func (p *gitlabProvider) AuthCodeOptions() []oauth2.AuthCodeOption {
+	return nil
+}
+
+func (p *gitlabProvider) Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	return p.service.Exchange(ctx, code, opts...)
+}
+
+// GetUserInfo reports the primary email as verified only when GitLab returns
+// a confirmed_at date for it. Instances that omit the field, or accounts that
+// never confirmed their address, are treated as unverified.
+func (p *gitlabProvider) GetUserInfo(ctx context.Context, token *oauth2.Token, nonce string) (*OAuthUserInfo, error) {
+	userInfo, err := p.service.GetUserInfo(token)
+	if err != nil {
+		return nil, err
+	}
+
+	firstName, lastName := parseFullName(userInfo.Name)
+	if firstName == "" {
+		firstName = userInfo.Username
+	}
+
+	return &OAuthUserInfo{
+		ProviderUserID: strconv.Itoa(userInfo.ID),
+		Email:          userInfo.Email,
+		EmailVerified:  userInfo.Email != "" && userInfo.ConfirmedAt != nil,
+		FirstName:      firstName,
+		LastName:       lastName,
+	}, nil
+}
+
+func (p *gitlabProvider) GetUserRepositories(ctx context.Context, token *oauth2.Token) ([]Repository, error) {
+	projects, err := p.service.GetUserRepositories(token)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make([]Repository, len(projects))
+	for i, project := range projects {
+		repos[i] = Repository{
+			ID:          strconv.Itoa(project.ID),
+			Name:        project.Name,
+			FullName:    project.PathWithNamespace,
+			Description: project.Description,
+			Private:     project.Visibility != "public",
+			HTMLURL:     project.WebURL,
+			CloneURL:    project.HTTPURLToRepo,
+			Fork:        len(project.ForkedFromProject) > 0 && string(project.ForkedFromProject) != "null",
+			CreatedAt:   project.CreatedAt,
+			UpdatedAt:   project.LastActivityAt,
+		}
+	}
+
+	return repos, nil
+}
@@ -51,8 +51,8 @@ func (s *GoogleService) GetAuthURL(state string) string {
 	return s.config.AuthCodeURL(state)
 }
 
-func (s *GoogleService) Exchange(ctx context.Context, code string) (*oauth2.Token, error) {
-	return s.config.Exchange(ctx, code)
+func (s *GoogleService) Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	return s.config.Exchange(ctx, code, opts...)
 }
 
 func (s *GoogleService) GetUserInfo(token *oauth2.Token) (*GoogleUserInfo, error) {
@@ -81,3 +81,42 @@ func (s *GoogleService) GetUserInfo(token *oauth2.Token) (*GoogleUserInfo, error
 
 	return &userInfo, nil
 }
+
+type googleProvider struct {
+	service *GoogleService
+}
+
+func NewGoogleProvider(service *GoogleService) OAuthProvider {
+	return &googleProvider{service: service}
+}
+
+func (p *googleProvider) Name() string {
+	return "google"
+}
+
+func (p *googleProvider) OAuthConfig() *oauth2.Config {
+	return p.service.config
+}
+
+func (p *googleProvider) AuthCodeOptions() []oauth2.AuthCodeOption {
+	return []oauth2.AuthCodeOption{oauth2.AccessTypeOffline}
+}
+
+func (p *googleProvider) Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	return p.service.Exchange(ctx, code, opts...)
+}
+
+func (p *googleProvider) GetUserInfo(ctx context.Context, token *oauth2.Token, nonce string) (*OAuthUserInfo, error) {
+	userInfo, err := p.service.GetUserInfo(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OAuthUserInfo{
+		ProviderUserID: userInfo.ID,
+		Email:          userInfo.Email,
+		EmailVerified:  userInfo.VerifiedEmail,
+		FirstName:      userInfo.GivenName,
+		LastName:       userInfo.FamilyName,
+	}, nil
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/oauth2"
+
+	"cloud-sprint/config"
+)
+
+// OAuthUserInfo is the provider-neutral identity returned after a successful
+// code exchange.
+type OAuthUserInfo struct {
+	ProviderUserID string
+	Email          string
+	EmailVerified  bool
+	FirstName      string
+	LastName       string
+}
+
+// The users table requires names of 2 to 255 characters. Providers return
+// whatever the identity carries, which may be empty or a single letter.
+const (
+	minNameLength     = 2
+	maxNameLength     = 255
+	fallbackFirstName = "User"
+	fallbackLastName  = "Unknown"
+)
+
+// AccountNames returns the first and last name to store for a new account,
+// replacing names the users table would reject with placeholders the user
+// can change later.
+func (info *OAuthUserInfo) AccountNames() (string, string) {
+	return accountName(info.FirstName, fallbackFirstName), accountName(info.LastName, fallbackLastName)
+}
+
+func accountName(name string, fallback string) string {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) < minNameLength {
+		return fallback
+	}
+
+	if utf8.RuneCountInString(name) > maxNameLength {
+		name = string([]rune(name)[:maxNameLength])
+	}
+	return name
+}
+
+// OAuthProvider adapts an OAuth 2.0 or OpenID Connect identity provider to the
+// shared sign-in flow. nonce is the value sent with the authorization request;
+// providers that return an ID token must check it.
+type OAuthProvider interface {
+	Name() string
+	OAuthConfig() *oauth2.Config
+	AuthCodeOptions() []oauth2.AuthCodeOption
+	Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error)
+	GetUserInfo(ctx context.Context, token *oauth2.Token, nonce string) (*OAuthUserInfo, error)
+}
+
+type OAuthProviderRegistry struct {
+	providers map[string]OAuthProvider
+}
+
+func NewOAuthProviderRegistry(providers ...OAuthProvider) *OAuthProviderRegistry {
+	registry := &OAuthProviderRegistry{
+		providers: make(map[string]OAuthProvider, len(providers)),
+	}
+
+	for _, provider := range providers {
+		registry.Register(provider)
+	}
+
+	return registry
+}
+
+// NewOAuthProviderRegistryFromConfig registers every built-in provider that
+// has a client ID configured and runs discovery for each OIDC provider.
+func NewOAuthProviderRegistryFromConfig(ctx context.Context, config config.Config) (*OAuthProviderRegistry, error) {
+	registry := NewOAuthProviderRegistry()
+
+	if config.OAuth.GoogleClientID != "" {
+		registry.Register(NewGoogleProvider(NewGoogleService(config)))
+	}
+
+	if config.OAuth.GitHubClientID != "" {
+		registry.Register(NewGitHubProvider(NewGitHubService(config)))
+	}
+
+	if config.OAuth.GitLabClientID != "" {
+		registry.Register(NewGitLabProvider(NewGitLabService(config)))
+	}
+
+	if config.OAuth.BitbucketClientID != "" {
+		registry.Register(NewBitbucketProvider(NewBitbucketService(config)))
+	}
+
+	for _, providerConfig := range config.OAuth.OIDCProviders {
+		provider, err := NewOIDCProvider(ctx, providerConfig)
+		if err != nil {
+			return nil, err
+		}
+		registry.Register(provider)
+	}
+
+	return registry, nil
+}
+
+func (r *OAuthProviderRegistry) Register(provider OAuthProvider) {
+	r.providers[provider.Name()] = provider
+}
+
+func (r *OAuthProviderRegistry) Get(name string) (OAuthProvider, bool) {
+	provider, ok := r.providers[name]
+	return provider, ok
+}
+
+func (r *OAuthProviderRegistry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/oauth2"
+
+	"cloud-sprint/config"
+)
+
+const (
+	testOIDCClientID = "cloud-sprint-client"
+	testOIDCKeyID    = "test-key"
+	testOIDCNonce    = "test-nonce"
+)
+
+// mockIdP serves the discovery document and the JWKS of an OpenID Connect
+// provider and signs ID tokens with its key.
+type mockIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	idp := &mockIdP{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"issuer":                                idp.server.URL,
+			"authorization_endpoint":                idp.server.URL + "/authorize",
+			"token_endpoint":                        idp.server.URL + "/token",
+			"jwks_uri":                              idp.server.URL + "/keys",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"keys": []map[string]any{{
+				"kty": "RSA",
+				"kid": testOIDCKeyID,
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func (idp *mockIdP) claims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"sub":            "user-123",
+		"aud":            testOIDCClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          testOIDCNonce,
+		"email":          "jane.doe@example.com",
+		"email_verified": true,
+		"given_name":     "Jane",
+		"family_name":    "Doe",
+	}
+}
+
+func (idp *mockIdP) token(t *testing.T, claims jwt.MapClaims, key *rsa.PrivateKey) *oauth2.Token {
+	t.Helper()
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = testOIDCKeyID
+
+	rawIDToken, err := idToken.SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign id_token: %v", err)
+	}
+
+	token := &oauth2.Token{AccessToken: "access-token", TokenType: "Bearer"}
+	return token.WithExtra(map[string]any{"id_token": rawIDToken})
+}
+
+func (idp *mockIdP) provider(t *testing.T) *OIDCProvider {
+	t.Helper()
+
+	provider, err := NewOIDCProvider(context.Background(), config.OIDCProviderConfig{
+		Name:           "mock",
+		IssuerURL:      idp.server.URL,
+		ClientID:       testOIDCClientID,
+		ClientSecret:   "secret",
+		RedirectURL:    "http://localhost:8080/api/v1/auth/oauth/mock/callback",
+		Scopes:         []string{"openid", "email", "profile"},
+		FirstNameClaim: "given_name",
+		LastNameClaim:  "family_name",
+	})
+	if err != nil {
+		t.Fatalf("NewOIDCProvider: %v", err)
+	}
+	return provider
+}
+
+func TestOIDCProviderValidIDToken(t *testing.T) {
+	idp := newMockIdP(t)
+	provider := idp.provider(t)
+
+	userInfo, err := provider.GetUserInfo(context.Background(), idp.token(t, idp.claims(), idp.key), testOIDCNonce)
+	if err != nil {
+		t.Fatalf("GetUserInfo: %v", err)
+	}
+
+	want := OAuthUserInfo{
+		ProviderUserID: "user-123",
+		Email:          "jane.doe@example.com",
+		EmailVerified:  true,
+		FirstName:      "Jane",
+		LastName:       "Doe",
+	}
+	if *userInfo != want {
+		t.Fatalf("GetUserInfo = %+v, want %+v", *userInfo, want)
+	}
+}
+
+func TestOIDCProviderBadSignature(t *testing.T) {
+	idp := newMockIdP(t)
+	provider := idp.provider(t)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	if _, err := provider.GetUserInfo(context.Background(), idp.token(t, idp.claims(), otherKey), testOIDCNonce); err == nil {
+		t.Fatal("expected an id_token signed with another key to be rejected")
+	}
+}
+
+func TestOIDCProviderWrongNonce(t *testing.T) {
+	idp := newMockIdP(t)
+	provider := idp.provider(t)
+
+	_, err := provider.GetUserInfo(context.Background(), idp.token(t, idp.claims(), idp.key), "other-nonce")
+	if !errors.Is(err, errNonceMismatch) {
+		t.Fatalf("GetUserInfo error = %v, want %v", err, errNonceMismatch)
+	}
+}
+
+func TestOIDCProviderWrongAudience(t *testing.T) {
+	idp := newMockIdP(t)
+	provider := idp.provider(t)
+
+	claims := idp.claims()
+	claims["aud"] = "another-client"
+
+	if _, err := provider.GetUserInfo(context.Background(), idp.token(t, claims, idp.key), testOIDCNonce); err == nil {
+		t.Fatal("expected an id_token for another client to be rejected")
+	}
+}
+
+func TestOIDCProviderMissingIDToken(t *testing.T) {
+	idp := newMockIdP(t)
+	provider := idp.provider(t)
+
+	_, err := provider.GetUserInfo(context.Background(), &oauth2.Token{AccessToken: "access-token"}, testOIDCNonce)
+	if !errors.Is(err, errMissingIDToken) {
+		t.Fatalf("GetUserInfo error = %v, want %v", err, errMissingIDToken)
+	}
+}
+
+// The provider falls back to the username and the email local part; names
+// still too short for the users table are replaced by AccountNames.
+func TestOIDCProviderNameFallbacks(t *testing.T) {
+	idp := newMockIdP(t)
+	provider := idp.provider(t)
+
+	tests := []struct {
+		name                 string
+		claims               map[string]any
+		wantFirstName        string
+		wantLastName         string
+		wantAccountFirstName string
+		wantAccountLastName  string
+	}{
+		{
+			name:                 "preferred username",
+			claims:               map[string]any{"preferred_username": "jdoe"},
+			wantFirstName:        "jdoe",
+			wantLastName:         "",
+			wantAccountFirstName: "jdoe",
+			wantAccountLastName:  "Unknown",
+		},
+		{
+			name:                 "email local part",
+			claims:               map[string]any{},
+			wantFirstName:        "jane.doe",
+			wantLastName:         "",
+			wantAccountFirstName: "jane.doe",
+			wantAccountLastName:  "Unknown",
+		},
+		{
+			name:                 "single letter email local part",
+			claims:               map[string]any{"email": "j@example.com"},
+			wantFirstName:        "j",
+			wantLastName:         "",
+			wantAccountFirstName: "User",
+			wantAccountLastName:  "Unknown",
+		},
+		{
+			name:                 "single letter names",
+			claims:               map[string]any{"given_name": "J", "family_name": "D"},
+			wantFirstName:        "J",
+			wantLastName:         "D",
+			wantAccountFirstName: "User",
+			wantAccountLastName:  "Unknown",
+		},
+		{
+			name:                 "no email",
+			claims:               map[string]any{"email": nil},
+			wantFirstName:        "",
+			wantLastName:         "",
+			wantAccountFirstName: "User",
+			wantAccountLastName:  "Unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := idp.claims()
+			delete(claims, "given_name")
+			delete(claims, "family_name")
+			for key, value := range tt.claims {
+				if value == nil {
+					delete(claims, key)
+					continue
+				}
+				claims[key] = value
+			}
+
+			userInfo, err := provider.GetUserInfo(context.Background(), idp.token(t, claims, idp.key), testOIDCNonce)
+			if err != nil {
+				t.Fatalf("GetUserInfo: %v", err)
+			}
+			if userInfo.FirstName != tt.wantFirstName || userInfo.LastName != tt.wantLastName {
+				t.Fatalf("name = %q %q, want %q %q", userInfo.FirstName, userInfo.LastName, tt.wantFirstName, tt.wantLastName)
+			}
+
+			firstName, lastName := userInfo.AccountNames()
+			if firstName != tt.wantAccountFirstName || lastName != tt.wantAccountLastName {
+				t.Fatalf("account name = %q %q, want %q %q", firstName, lastName, tt.wantAccountFirstName, tt.wantAccountLastName)
+			}
+		})
+	}
+}
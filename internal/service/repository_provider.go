@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Repository is the provider-neutral view of a code repository.
+type Repository struct {
+	ID          string
+	Name        string
+	FullName    string
+	Description string
+	Private     bool
+	HTMLURL     string
+	CloneURL    string
+	Language    string
+	Fork        bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// RepositoryProvider is implemented by OAuth providers that host code. The
+// token is the one stored in oauth_accounts for the signed in user.
+type RepositoryProvider interface {
+	OAuthProvider
+	GetUserRepositories(ctx context.Context, token *oauth2.Token) ([]Repository, error)
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+
+	"cloud-sprint/config"
+)
+
+// WebAuthnUser adapts an account and its registered credentials to the
+// webauthn.User interface. The user handle is the account ID so no personal
+// data is stored on the authenticator.
+type WebAuthnUser struct {
+	ID          []byte
+	Name        string
+	DisplayName string
+	Credentials []webauthn.Credential
+}
+
+func (u *WebAuthnUser) WebAuthnID() []byte {
+	return u.ID
+}
+
+func (u *WebAuthnUser) WebAuthnName() string {
+	return u.Name
+}
+
+func (u *WebAuthnUser) WebAuthnDisplayName() string {
+	return u.DisplayName
+}
+
+func (u *WebAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.Credentials
+}
+
+// WebAuthnService runs the registration and assertion ceremonies for passkeys.
+// Passkeys are registered as discoverable credentials with user verification,
+// so they can sign in without a password or a username.
+type WebAuthnService struct {
+	webAuthn *webauthn.WebAuthn
+}
+
+func NewWebAuthnService(config config.Config) (*WebAuthnService, error) {
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          config.WebAuthn.RPID,
+		RPDisplayName: config.WebAuthn.RPDisplayName,
+		RPOrigins:     config.WebAuthn.RPOrigins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementRequired,
+			UserVerification: protocol.VerificationRequired,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebAuthnService{
+		webAuthn: webAuthn,
+	}, nil
+}
+
+// BeginRegistration excludes the credentials the user already registered so
+// the same authenticator is not enrolled twice.
+func (s *WebAuthnService) BeginRegistration(user *WebAuthnUser) (*protocol.CredentialCreation, *webauthn.SessionData, error) {
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.Credentials))
+	for _, credential := range user.Credentials {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	return s.webAuthn.BeginRegistration(user, webauthn.WithExclusions(exclusions))
+}
+
+func (s *WebAuthnService) FinishRegistration(user *WebAuthnUser, session webauthn.SessionData, body []byte) (*webauthn.Credential, error) {
+	parsedResponse, err := protocol.ParseCredentialCreationResponseBytes(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.webAuthn.CreateCredential(user, session, parsedResponse)
+}
+
+func (s *WebAuthnService) BeginLogin() (*protocol.CredentialAssertion, *webauthn.SessionData, error) {
+	return s.webAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+}
+
+// FinishLogin resolves the user from the user handle returned by the
+// authenticator and verifies the assertion against its stored credentials.
+// The library only checks the ceremony expiry for non-discoverable logins, so
+// it is checked here.
+func (s *WebAuthnService) FinishLogin(findUser webauthn.DiscoverableUserHandler, session webauthn.SessionData, body []byte) (webauthn.User, *webauthn.Credential, error) {
+	if !session.Expires.IsZero() && session.Expires.Before(time.Now()) {
+		return nil, nil, protocol.ErrBadRequest.WithDetails("Session has Expired")
+	}
+
+	parsedResponse, err := protocol.ParseCredentialRequestResponseBytes(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.webAuthn.ValidatePasskeyLogin(findUser, session, parsedResponse)
+}
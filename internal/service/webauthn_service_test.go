@@ -0,0 +1,324 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+
+	"cloud-sprint/config"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:3000"
+)
+
+// softAuthenticator is a platform authenticator in software: it holds one P-256
+// credential, answers with "none" attestation and counts signatures.
+type softAuthenticator struct {
+	credentialID []byte
+	privateKey   *ecdsa.PrivateKey
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatalf("failed to generate credential ID: %v", err)
+	}
+
+	return &softAuthenticator{
+		credentialID: credentialID,
+		privateKey:   privateKey,
+	}
+}
+
+func (a *softAuthenticator) authenticatorData(flags protocol.AuthenticatorFlags, attestedCredential []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, byte(flags))
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attestedCredential...)
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, ceremonyType protocol.CeremonyType, challenge string) []byte {
+	t.Helper()
+
+	clientData, err := json.Marshal(protocol.CollectedClientData{
+		Type:      ceremonyType,
+		Challenge: challenge,
+		Origin:    testOrigin,
+	})
+	if err != nil {
+		t.Fatalf("failed to encode client data: %v", err)
+	}
+	return clientData
+}
+
+// register answers the creation options the way navigator.credentials.create
+// would and returns the JSON body the browser posts back.
+func (a *softAuthenticator) register(t *testing.T, creation *protocol.CredentialCreation) []byte {
+	t.Helper()
+
+	a.userHandle = creation.Response.User.ID.(protocol.URLEncodedBase64)
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: a.privateKey.X.FillBytes(make([]byte, 32)),
+		YCoord: a.privateKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatalf("failed to encode public key: %v", err)
+	}
+
+	attestedCredential := make([]byte, 16) // zero AAGUID
+	attestedCredential = binary.BigEndian.AppendUint16(attestedCredential, uint16(len(a.credentialID)))
+	attestedCredential = append(attestedCredential, a.credentialID...)
+	attestedCredential = append(attestedCredential, publicKey...)
+
+	flags := protocol.FlagUserPresent | protocol.FlagUserVerified | protocol.FlagAttestedCredentialData
+	attestationObject, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authenticatorData(flags, attestedCredential),
+	})
+	if err != nil {
+		t.Fatalf("failed to encode attestation object: %v", err)
+	}
+
+	return mustJSON(t, map[string]any{
+		"id":    encode(a.credentialID),
+		"rawId": encode(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    encode(a.clientData(t, protocol.CreateCeremony, creation.Response.Challenge.String())),
+			"attestationObject": encode(attestationObject),
+		},
+	})
+}
+
+// assert answers the request options the way navigator.credentials.get would,
+// bumping the signature counter first.
+func (a *softAuthenticator) assert(t *testing.T, assertion *protocol.CredentialAssertion) []byte {
+	t.Helper()
+
+	a.signCount++
+
+	clientData := a.clientData(t, protocol.AssertCeremony, assertion.Response.Challenge.String())
+	authData := a.authenticatorData(protocol.FlagUserPresent|protocol.FlagUserVerified, nil)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.privateKey, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign assertion: %v", err)
+	}
+
+	return mustJSON(t, map[string]any{
+		"id":    encode(a.credentialID),
+		"rawId": encode(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    encode(clientData),
+			"authenticatorData": encode(authData),
+			"signature":         encode(signature),
+			"userHandle":        encode(a.userHandle),
+		},
+	})
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func mustJSON(t *testing.T, value any) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("failed to encode response: %v", err)
+	}
+	return data
+}
+
+func newTestWebAuthnService(t *testing.T) *WebAuthnService {
+	t.Helper()
+
+	service, err := NewWebAuthnService(config.Config{
+		WebAuthn: config.WebAuthnConfig{
+			RPID:          testRPID,
+			RPDisplayName: "CloudSprint",
+			RPOrigins:     []string{testOrigin},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create webauthn service: %v", err)
+	}
+	return service
+}
+
+func newTestWebAuthnUser() *WebAuthnUser {
+	return &WebAuthnUser{
+		ID:          []byte("3f8b0f5e-6f0e-4a57-9a55-2a8d1b1c7e10"),
+		Name:        "user@example.com",
+		DisplayName: "user@example.com",
+	}
+}
+
+// registerPasskey runs a full registration and stores the credential on user.
+func registerPasskey(t *testing.T, service *WebAuthnService, authenticator *softAuthenticator, user *WebAuthnUser) *webauthn.Credential {
+	t.Helper()
+
+	creation, session, err := service.BeginRegistration(user)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+
+	credential, err := service.FinishRegistration(user, *session, authenticator.register(t, creation))
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+
+	user.Credentials = append(user.Credentials, *credential)
+	return credential
+}
+
+func login(t *testing.T, service *WebAuthnService, authenticator *softAuthenticator, user *WebAuthnUser) (*webauthn.Credential, error) {
+	t.Helper()
+
+	assertion, session, err := service.BeginLogin()
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+
+	findUser := func(rawID, userHandle []byte) (webauthn.User, error) {
+		return user, nil
+	}
+
+	_, credential, err := service.FinishLogin(findUser, *session, authenticator.assert(t, assertion))
+	return credential, err
+}
+
+func TestWebAuthnRegistrationAndLogin(t *testing.T) {
+	service := newTestWebAuthnService(t)
+	authenticator := newSoftAuthenticator(t)
+	user := newTestWebAuthnUser()
+
+	registered := registerPasskey(t, service, authenticator, user)
+	if string(registered.ID) != string(authenticator.credentialID) {
+		t.Fatalf("registered credential ID = %x, want %x", registered.ID, authenticator.credentialID)
+	}
+	if !registered.Flags.UserVerified {
+		t.Fatal("registered credential is not user verified")
+	}
+
+	credential, err := login(t, service, authenticator, user)
+	if err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+	if credential.Authenticator.CloneWarning {
+		t.Fatal("unexpected clone warning")
+	}
+	if credential.Authenticator.SignCount != authenticator.signCount {
+		t.Fatalf("sign count = %d, want %d", credential.Authenticator.SignCount, authenticator.signCount)
+	}
+}
+
+func TestWebAuthnRegistrationExcludesRegisteredCredentials(t *testing.T) {
+	service := newTestWebAuthnService(t)
+	user := newTestWebAuthnUser()
+	registerPasskey(t, service, newSoftAuthenticator(t), user)
+
+	creation, _, err := service.BeginRegistration(user)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+	if len(creation.Response.CredentialExcludeList) != 1 {
+		t.Fatalf("exclude list has %d credentials, want 1", len(creation.Response.CredentialExcludeList))
+	}
+}
+
+func TestWebAuthnLoginCloneWarning(t *testing.T) {
+	service := newTestWebAuthnService(t)
+	authenticator := newSoftAuthenticator(t)
+	user := newTestWebAuthnUser()
+	registerPasskey(t, service, authenticator, user)
+
+	credential, err := login(t, service, authenticator, user)
+	if err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+	user.Credentials[0] = *credential
+
+	// A copy of the key that has not seen the last signature answers with a
+	// counter that does not move forward.
+	authenticator.signCount--
+
+	credential, err = login(t, service, authenticator, user)
+	if err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+	if !credential.Authenticator.CloneWarning {
+		t.Fatal("expected clone warning for a sign count that did not increase")
+	}
+}
+
+func TestWebAuthnExpiredRegistration(t *testing.T) {
+	service := newTestWebAuthnService(t)
+	authenticator := newSoftAuthenticator(t)
+	user := newTestWebAuthnUser()
+
+	creation, session, err := service.BeginRegistration(user)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+	session.Expires = time.Now().Add(-time.Second)
+
+	if _, err := service.FinishRegistration(user, *session, authenticator.register(t, creation)); err == nil {
+		t.Fatal("expected an expired registration ceremony to fail")
+	}
+}
+
+func TestWebAuthnExpiredLogin(t *testing.T) {
+	service := newTestWebAuthnService(t)
+	authenticator := newSoftAuthenticator(t)
+	user := newTestWebAuthnUser()
+	registerPasskey(t, service, authenticator, user)
+
+	assertion, session, err := service.BeginLogin()
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	session.Expires = time.Now().Add(-time.Second)
+
+	findUser := func(rawID, userHandle []byte) (webauthn.User, error) {
+		return user, nil
+	}
+	if _, _, err := service.FinishLogin(findUser, *session, authenticator.assert(t, assertion)); err == nil {
+		t.Fatal("expected an expired login ceremony to fail")
+	}
+}
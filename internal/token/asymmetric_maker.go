@@ -0,0 +1,25 @@
+package token
+
+import "fmt"
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// NewAsymmetricJWTMaker signs access tokens with an RSA or Ed25519 keyring so
+// that other services can verify them with the published public keys.
+// Refresh, MFA and magic-link tokens are signed with the internal keyring,
+// which must hold shared secrets so it is never published.
+func NewAsymmetricJWTMaker(keys *Keyring, internalKeys *Keyring) (Maker, error) {
+	if keys.Active().publicKey == nil {
+		return nil, fmt.Errorf("active key %q is not an asymmetric key", keys.Active().ID())
+	}
+
+	if internalKeys == nil || internalKeys.Active().publicKey != nil {
+		return nil, fmt.Errorf("refresh tokens require a secret key that is not published")
+	}
+
+	return NewJWTMakerWithKeyrings(keys, internalKeys)
+}
@@ -0,0 +1,57 @@
+package token
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	DenylistMemory   = "memory"
+	DenylistDatabase = "database"
+)
+
+// Denylist keeps revoked token and session IDs until they expire. Sign-out and
+// single use MFA tokens rely on it, so every instance of the API must share
+// the same store.
+type Denylist interface {
+	Add(ctx context.Context, id string, expiresAt time.Time) error
+	Contains(ctx context.Context, id string) (bool, error)
+}
+
+// MemoryDenylist keeps entries in process memory. Entries are lost on restart
+// and are not seen by other instances, so it is only suitable for a single
+// node and for development.
+type MemoryDenylist struct {
+	mu      sync.RWMutex
+	entries map[string]time.Time
+}
+
+func NewMemoryDenylist() *MemoryDenylist {
+	return &MemoryDenylist{
+		entries: make(map[string]time.Time),
+	}
+}
+
+func (d *MemoryDenylist) Add(_ context.Context, id string, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	for key, expiry := range d.entries {
+		if now.After(expiry) {
+			delete(d.entries, key)
+		}
+	}
+
+	d.entries[id] = expiresAt
+	return nil
+}
+
+func (d *MemoryDenylist) Contains(_ context.Context, id string) (bool, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	expiresAt, ok := d.entries[id]
+	return ok && time.Now().Before(expiresAt), nil
+}
@@ -16,17 +16,21 @@ var (
 )
 
 type Payload struct {
+	ID        string              `json:"jti"`
 	UserID    string              `json:"user_id"`
 	Email     string              `json:"email"`
+	SessionID string              `json:"session_id"`
 	IssuedAt  time.Time           `json:"issued_at"`
 	ExpiredAt time.Time           `json:"expired_at"`
 	TokenType constants.TokenType `json:"token_type"`
 }
 
-func NewPayload(userID uuid.UUID, email string, duration time.Duration, tokenType constants.TokenType) (*Payload, error) {
+func NewPayload(userID uuid.UUID, email string, sessionID uuid.UUID, duration time.Duration, tokenType constants.TokenType) (*Payload, error) {
 	payload := &Payload{
+		ID:        uuid.New().String(),
 		UserID:    userID.String(),
 		Email:     email,
+		SessionID: sessionID.String(),
 		IssuedAt:  time.Now(),
 		ExpiredAt: time.Now().Add(duration),
 		TokenType: tokenType,
@@ -41,95 +45,188 @@ func (payload *Payload) Valid() error {
 	return nil
 }
 
+// Audience is the aud claim of a token kind. Services that verify tokens
+// offline with the published keys must only accept Audience(AccessTokenType).
+func Audience(tokenType constants.TokenType) string {
+	return "cloud-sprint:" + string(tokenType)
+}
+
+// headerType is the JOSE typ header of a token kind. Access tokens use the
+// RFC 9068 value so standard resource servers accept them.
+func headerType(tokenType constants.TokenType) string {
+	if tokenType == constants.AccessTokenType {
+		return "at+jwt"
+	}
+	return string(tokenType) + "+jwt"
+}
+
+// jwtClaims adds the registered claims to the payload so that standard JWT
+// libraries enforce expiry and audience, not only this package.
+type jwtClaims struct {
+	Payload
+	Sub string           `json:"sub"`
+	Aud jwt.ClaimStrings `json:"aud"`
+	Exp *jwt.NumericDate `json:"exp"`
+	Iat *jwt.NumericDate `json:"iat"`
+	Nbf *jwt.NumericDate `json:"nbf"`
+}
+
+func newJWTClaims(payload *Payload) *jwtClaims {
+	return &jwtClaims{
+		Payload: *payload,
+		Sub:     payload.UserID,
+		Aud:     jwt.ClaimStrings{Audience(payload.TokenType)},
+		Exp:     jwt.NewNumericDate(payload.ExpiredAt),
+		Iat:     jwt.NewNumericDate(payload.IssuedAt),
+		Nbf:     jwt.NewNumericDate(payload.IssuedAt),
+	}
+}
+
 type Maker interface {
-	CreateToken(userID uuid.UUID, email string, duration time.Duration) (string, *Payload, error)
+	CreateToken(userID uuid.UUID, email string, sessionID uuid.UUID, duration time.Duration) (string, *Payload, error)
 	VerifyToken(token string) (*Payload, error)
-	CreateRefreshToken(userID uuid.UUID, email string, duration time.Duration) (string, *Payload, error)
+	CreateRefreshToken(userID uuid.UUID, email string, sessionID uuid.UUID, duration time.Duration) (string, *Payload, error)
 	VerifyRefreshToken(refreshToken string) (*Payload, error)
+	CreateMFAToken(userID uuid.UUID, email string, duration time.Duration) (string, *Payload, error)
+	VerifyMFAToken(mfaToken string) (*Payload, error)
+	CreateMagicLinkToken(userID uuid.UUID, email string, duration time.Duration) (string, *Payload, error)
+	VerifyMagicLinkToken(magicLinkToken string) (*Payload, error)
 }
 
+// JWTMaker signs access tokens with the access keyring and every other token
+// kind with the internal keyring. Only the access keyring is ever published,
+// so refresh, MFA and magic-link tokens cannot be verified outside this
+// service.
 type JWTMaker struct {
-	accessTokenSecretKey  string
-	refreshTokenSecretKey string
+	accessKeys   *Keyring
+	internalKeys *Keyring
 }
 
 func NewJWTMaker(accessTokenSecretKey, refreshTokenSecretKey string) (Maker, error) {
-	if len(accessTokenSecretKey) < 32 {
-		return nil, fmt.Errorf("invalid access token key size: must be at least 32 characters")
+	accessKeys, err := NewHMACKeyring(DefaultKeyID, accessTokenSecretKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid access token key: %w", err)
 	}
 
-	if len(refreshTokenSecretKey) < 32 {
-		return nil, fmt.Errorf("invalid refresh token key size: must be at least 32 characters")
+	refreshKeys, err := NewHMACKeyring(DefaultKeyID, refreshTokenSecretKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid refresh token key: %w", err)
+	}
+
+	return NewJWTMakerWithKeyrings(accessKeys, refreshKeys)
+}
+
+func NewJWTMakerWithKeyrings(accessKeys, internalKeys *Keyring) (Maker, error) {
+	if accessKeys == nil || internalKeys == nil {
+		return nil, fmt.Errorf("access and refresh keyrings are required")
+	}
+
+	if accessKeys == internalKeys {
+		return nil, fmt.Errorf("access and refresh tokens must not share a keyring")
 	}
 
 	return &JWTMaker{
-		accessTokenSecretKey:  accessTokenSecretKey,
-		refreshTokenSecretKey: refreshTokenSecretKey,
+		accessKeys:   accessKeys,
+		internalKeys: internalKeys,
 	}, nil
 }
 
-func (maker *JWTMaker) CreateToken(userID uuid.UUID, email string, duration time.Duration) (string, *Payload, error) {
-	payload, err := NewPayload(userID, email, duration, constants.AccessTokenType)
+func (maker *JWTMaker) CreateToken(userID uuid.UUID, email string, sessionID uuid.UUID, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(userID, email, sessionID, duration, constants.AccessTokenType)
 	if err != nil {
 		return "", nil, err
 	}
 
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
-	token, err := jwtToken.SignedString([]byte(maker.accessTokenSecretKey))
+	token, err := maker.accessKeys.Sign(newJWTClaims(payload), headerType(payload.TokenType))
 	return token, payload, err
 }
 
 func (maker *JWTMaker) VerifyToken(token string) (*Payload, error) {
-	return maker.verifyTokenWithSecret(token, maker.accessTokenSecretKey)
+	return maker.verifyToken(token, maker.accessKeys, constants.AccessTokenType)
 }
 
-func (maker *JWTMaker) CreateRefreshToken(userID uuid.UUID, email string, duration time.Duration) (string, *Payload, error) {
-	payload, err := NewPayload(userID, email, duration, constants.RefreshTokenType)
+func (maker *JWTMaker) CreateRefreshToken(userID uuid.UUID, email string, sessionID uuid.UUID, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(userID, email, sessionID, duration, constants.RefreshTokenType)
 	if err != nil {
 		return "", nil, err
 	}
 
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
-	token, err := jwtToken.SignedString([]byte(maker.refreshTokenSecretKey))
+	token, err := maker.internalKeys.Sign(newJWTClaims(payload), headerType(payload.TokenType))
 	return token, payload, err
 }
 
 func (maker *JWTMaker) VerifyRefreshToken(refreshToken string) (*Payload, error) {
-	payload, err := maker.verifyTokenWithSecret(refreshToken, maker.refreshTokenSecretKey)
+	return maker.verifyToken(refreshToken, maker.internalKeys, constants.RefreshTokenType)
+}
+
+// CreateMFAToken issues the short lived token that proves the password step
+// of a sign-in succeeded. It is not bound to a session.
+func (maker *JWTMaker) CreateMFAToken(userID uuid.UUID, email string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(userID, email, uuid.Nil, duration, constants.MFATokenType)
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
 
-	if payload.TokenType != constants.RefreshTokenType {
-		return nil, ErrInvalidToken
-	}
+	token, err := maker.internalKeys.Sign(newJWTClaims(payload), headerType(payload.TokenType))
+	return token, payload, err
+}
 
-	return payload, nil
+func (maker *JWTMaker) VerifyMFAToken(mfaToken string) (*Payload, error) {
+	return maker.verifyToken(mfaToken, maker.internalKeys, constants.MFATokenType)
 }
 
-func (maker *JWTMaker) verifyTokenWithSecret(token string, secretKey string) (*Payload, error) {
-	keyFunc := func(token *jwt.Token) (interface{}, error) {
-		_, ok := token.Method.(*jwt.SigningMethodHMAC)
-		if !ok {
-			return nil, ErrInvalidToken
-		}
-		return []byte(secretKey), nil
+// CreateMagicLinkToken issues the token embedded in an emailed sign-in link.
+// Its ID is recorded so the link can only be used once.
+func (maker *JWTMaker) CreateMagicLinkToken(userID uuid.UUID, email string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(userID, email, uuid.Nil, duration, constants.MagicLinkTokenType)
+	if err != nil {
+		return "", nil, err
 	}
 
-	jwtToken, err := jwt.ParseWithClaims(token, &Payload{}, keyFunc)
+	token, err := maker.internalKeys.Sign(newJWTClaims(payload), headerType(payload.TokenType))
+	return token, payload, err
+}
+
+func (maker *JWTMaker) VerifyMagicLinkToken(magicLinkToken string) (*Payload, error) {
+	return maker.verifyToken(magicLinkToken, maker.internalKeys, constants.MagicLinkTokenType)
+}
+
+// JWKS returns the public keys of the access keyring. It is empty when tokens
+// are signed with shared secrets.
+func (maker *JWTMaker) JWKS() JWKS {
+	return maker.accessKeys.JWKS()
+}
+
+func (maker *JWTMaker) verifyToken(token string, keys *Keyring, tokenType constants.TokenType) (*Payload, error) {
+	jwtToken, err := jwt.ParseWithClaims(token, &jwtClaims{}, keys.KeyFunc)
 	if err != nil {
 		verr, ok := err.(*jwt.ValidationError)
 		if ok && errors.Is(verr.Inner, ErrExpiredToken) {
 			return nil, ErrExpiredToken
 		}
-		fmt.Println("Error verifying token:", err)
 		return nil, ErrInvalidToken
 	}
 
-	payload, ok := jwtToken.Claims.(*Payload)
-	if !ok {
+	claims, ok := jwtToken.Claims.(*jwtClaims)
+	if !ok || claims.TokenType != tokenType {
 		return nil, ErrInvalidToken
 	}
 
-	return payload, nil
+	if typ, _ := jwtToken.Header["typ"].(string); typ != headerType(tokenType) {
+		return nil, ErrInvalidToken
+	}
+	if !containsAudience(claims.Aud, Audience(tokenType)) {
+		return nil, ErrInvalidToken
+	}
+
+	return &claims.Payload, nil
+}
+
+func containsAudience(audiences jwt.ClaimStrings, audience string) bool {
+	for _, aud := range audiences {
+		if aud == audience {
+			return true
+		}
+	}
+	return false
 }
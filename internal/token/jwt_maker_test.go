@@ -0,0 +1,312 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+
+	"cloud-sprint/internal/constants"
+)
+
+const (
+	testAccessSecret  = "access-secret-that-is-long-enough-for-hs256"
+	testRefreshSecret = "refresh-secret-that-is-long-enough-for-hs256"
+)
+
+// tokenKind creates and verifies one kind of token with a maker.
+type tokenKind struct {
+	tokenType constants.TokenType
+	create    func(maker Maker, userID uuid.UUID, sessionID uuid.UUID, duration time.Duration) (string, *Payload, error)
+	verify    func(maker Maker, token string) (*Payload, error)
+}
+
+var tokenKinds = []tokenKind{
+	{
+		tokenType: constants.AccessTokenType,
+		create: func(maker Maker, userID uuid.UUID, sessionID uuid.UUID, duration time.Duration) (string, *Payload, error) {
+			return maker.CreateToken(userID, "user@example.com", sessionID, duration)
+		},
+		verify: Maker.VerifyToken,
+	},
+	{
+		tokenType: constants.RefreshTokenType,
+		create: func(maker Maker, userID uuid.UUID, sessionID uuid.UUID, duration time.Duration) (string, *Payload, error) {
+			return maker.CreateRefreshToken(userID, "user@example.com", sessionID, duration)
+		},
+		verify: Maker.VerifyRefreshToken,
+	},
+	{
+		tokenType: constants.MFATokenType,
+		create: func(maker Maker, userID uuid.UUID, sessionID uuid.UUID, duration time.Duration) (string, *Payload, error) {
+			return maker.CreateMFAToken(userID, "user@example.com", duration)
+		},
+		verify: Maker.VerifyMFAToken,
+	},
+	{
+		tokenType: constants.MagicLinkTokenType,
+		create: func(maker Maker, userID uuid.UUID, sessionID uuid.UUID, duration time.Duration) (string, *Payload, error) {
+			return maker.CreateMagicLinkToken(userID, "user@example.com", duration)
+		},
+		verify: Maker.VerifyMagicLinkToken,
+	},
+}
+
+// writeKey stores a private key as PKCS#8 PEM and returns its path.
+func writeKey(t *testing.T, key crypto.Signer) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to encode key: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return path
+}
+
+func newRSAKeyPath(t *testing.T) string {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return writeKey(t, key)
+}
+
+func newEd25519KeyPath(t *testing.T) string {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return writeKey(t, key)
+}
+
+func newTestHMACKeyring(t *testing.T, id string, secret string, verificationSecrets map[string]string) *Keyring {
+	t.Helper()
+
+	keys, err := NewHMACKeyring(id, secret, verificationSecrets)
+	if err != nil {
+		t.Fatalf("NewHMACKeyring: %v", err)
+	}
+	return keys
+}
+
+func newTestAsymmetricMaker(t *testing.T, algorithm string, privateKeyPath string) Maker {
+	t.Helper()
+
+	keys, err := NewAsymmetricKeyring("", algorithm, privateKeyPath, nil)
+	if err != nil {
+		t.Fatalf("NewAsymmetricKeyring: %v", err)
+	}
+
+	maker, err := NewAsymmetricJWTMaker(keys, newTestHMACKeyring(t, "", testRefreshSecret, nil))
+	if err != nil {
+		t.Fatalf("NewAsymmetricJWTMaker: %v", err)
+	}
+	return maker
+}
+
+func newTestJWTMakers(t *testing.T) map[string]Maker {
+	t.Helper()
+
+	hmacMaker, err := NewJWTMaker(testAccessSecret, testRefreshSecret)
+	if err != nil {
+		t.Fatalf("NewJWTMaker: %v", err)
+	}
+
+	return map[string]Maker{
+		AlgorithmHS256: hmacMaker,
+		AlgorithmRS256: newTestAsymmetricMaker(t, AlgorithmRS256, newRSAKeyPath(t)),
+		AlgorithmEdDSA: newTestAsymmetricMaker(t, AlgorithmEdDSA, newEd25519KeyPath(t)),
+	}
+}
+
+// testRoundTrip checks that every token kind verifies as itself and as no
+// other kind.
+func testRoundTrip(t *testing.T, maker Maker) {
+	for _, kind := range tokenKinds {
+		t.Run(string(kind.tokenType), func(t *testing.T) {
+			userID := uuid.New()
+			sessionID := uuid.New()
+
+			token, created, err := kind.create(maker, userID, sessionID, time.Minute)
+			if err != nil {
+				t.Fatalf("create: %v", err)
+			}
+
+			payload, err := kind.verify(maker, token)
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			if payload.ID != created.ID || payload.UserID != userID.String() || payload.TokenType != kind.tokenType {
+				t.Fatalf("payload = %+v, want %+v", payload, created)
+			}
+
+			for _, other := range tokenKinds {
+				if other.tokenType == kind.tokenType {
+					continue
+				}
+				if _, err := other.verify(maker, token); !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("%s token verified as %s: err = %v", kind.tokenType, other.tokenType, err)
+				}
+			}
+		})
+	}
+}
+
+func testExpired(t *testing.T, maker Maker) {
+	for _, kind := range tokenKinds {
+		t.Run(string(kind.tokenType), func(t *testing.T) {
+			token, _, err := kind.create(maker, uuid.New(), uuid.New(), -time.Minute)
+			if err != nil {
+				t.Fatalf("create: %v", err)
+			}
+
+			if _, err := kind.verify(maker, token); !errors.Is(err, ErrExpiredToken) {
+				t.Fatalf("verify error = %v, want %v", err, ErrExpiredToken)
+			}
+		})
+	}
+}
+
+func TestJWTMakerRoundTrip(t *testing.T) {
+	for name, maker := range newTestJWTMakers(t) {
+		t.Run(name, func(t *testing.T) {
+			testRoundTrip(t, maker)
+		})
+	}
+}
+
+func TestJWTMakerExpiredToken(t *testing.T) {
+	for name, maker := range newTestJWTMakers(t) {
+		t.Run(name, func(t *testing.T) {
+			testExpired(t, maker)
+		})
+	}
+}
+
+func TestJWTMakerTamperedToken(t *testing.T) {
+	for name, maker := range newTestJWTMakers(t) {
+		t.Run(name, func(t *testing.T) {
+			token, _, err := maker.CreateToken(uuid.New(), "user@example.com", uuid.New(), time.Minute)
+			if err != nil {
+				t.Fatalf("CreateToken: %v", err)
+			}
+
+			parts := strings.Split(token, ".")
+			claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+			if err != nil {
+				t.Fatalf("failed to decode claims: %v", err)
+			}
+
+			var payload map[string]any
+			if err := json.Unmarshal(claims, &payload); err != nil {
+				t.Fatalf("failed to decode claims: %v", err)
+			}
+			payload["user_id"] = uuid.NewString()
+			claims, err = json.Marshal(payload)
+			if err != nil {
+				t.Fatalf("failed to encode claims: %v", err)
+			}
+			parts[1] = base64.RawURLEncoding.EncodeToString(claims)
+
+			if _, err := maker.VerifyToken(strings.Join(parts, ".")); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("VerifyToken error = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestJWTMakerRejectsNoneAlgorithm(t *testing.T) {
+	maker := newTestJWTMakers(t)[AlgorithmRS256]
+
+	_, payload, err := maker.CreateToken(uuid.New(), "user@example.com", uuid.New(), time.Minute)
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, newJWTClaims(payload))
+	unsigned.Header["typ"] = headerType(constants.AccessTokenType)
+	token, err := unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("failed to encode token: %v", err)
+	}
+
+	if _, err := maker.VerifyToken(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("VerifyToken error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+// Only access tokens may be verifiable with the published key set; every
+// other kind is signed with a secret that never leaves the service.
+func TestJWTMakerJWKSOnlyVerifiesAccessTokens(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmRS256, AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			maker := newTestJWTMakers(t)[algorithm]
+
+			keySet := maker.(KeySetProvider).JWKS()
+			if len(keySet.Keys) != 1 {
+				t.Fatalf("JWKS has %d keys, want 1", len(keySet.Keys))
+			}
+
+			for _, kind := range tokenKinds {
+				token, _, err := kind.create(maker, uuid.New(), uuid.New(), time.Minute)
+				if err != nil {
+					t.Fatalf("create %s: %v", kind.tokenType, err)
+				}
+
+				jwtToken, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+				if err != nil {
+					t.Fatalf("failed to parse %s token: %v", kind.tokenType, err)
+				}
+
+				published := jwtToken.Header["kid"] == keySet.Keys[0].Kid && jwtToken.Method.Alg() == keySet.Keys[0].Alg
+				if published != (kind.tokenType == constants.AccessTokenType) {
+					t.Fatalf("%s token signed with kid %v alg %s, published key %s %s", kind.tokenType, jwtToken.Header["kid"], jwtToken.Method.Alg(), keySet.Keys[0].Kid, keySet.Keys[0].Alg)
+				}
+			}
+		})
+	}
+}
+
+func TestJWTMakerHMACHasNoJWKS(t *testing.T) {
+	maker := newTestJWTMakers(t)[AlgorithmHS256]
+
+	if keys := maker.(KeySetProvider).JWKS().Keys; len(keys) != 0 {
+		t.Fatalf("JWKS has %d keys, want none", len(keys))
+	}
+}
+
+func TestNewAsymmetricJWTMakerRequiresSecretInternalKeys(t *testing.T) {
+	keys, err := NewAsymmetricKeyring("", AlgorithmEdDSA, newEd25519KeyPath(t), nil)
+	if err != nil {
+		t.Fatalf("NewAsymmetricKeyring: %v", err)
+	}
+
+	if _, err := NewAsymmetricJWTMaker(keys, keys); err == nil {
+		t.Fatal("expected the published keyring to be refused for internal tokens")
+	}
+	if _, err := NewAsymmetricJWTMaker(keys, nil); err == nil {
+		t.Fatal("expected a missing internal keyring to be refused")
+	}
+}
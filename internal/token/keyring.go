@@ -0,0 +1,315 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const DefaultKeyID = "default"
+
+// SigningKey is a key identified by its kid. Keys without a private part can
+// only be used to verify tokens.
+type SigningKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+	publicKey crypto.PublicKey
+}
+
+func (key SigningKey) ID() string {
+	return key.id
+}
+
+func (key SigningKey) CanSign() bool {
+	return key.signKey != nil
+}
+
+func NewHMACKey(id string, secret string) (SigningKey, error) {
+	if len(secret) < 32 {
+		return SigningKey{}, fmt.Errorf("invalid key size for %q: must be at least 32 characters", id)
+	}
+
+	return SigningKey{
+		id:        id,
+		method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}, nil
+}
+
+// NewPrivateKey loads an RSA or Ed25519 signing key. The kid defaults to the
+// RFC 7638 thumbprint of the public key.
+func NewPrivateKey(id string, algorithm string, path string) (SigningKey, error) {
+	privateKey, err := LoadPrivateKey(path)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	method, err := signingMethodForKey(algorithm, privateKey.Public())
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	return newAsymmetricKey(id, method, privateKey, privateKey.Public())
+}
+
+// NewVerificationKey loads a verification-only key from a PEM file holding
+// either the public key or the retired private key.
+func NewVerificationKey(id string, path string) (SigningKey, error) {
+	publicKey, err := LoadPublicKey(path)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	method, err := signingMethodForKey("", publicKey)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	return newAsymmetricKey(id, method, nil, publicKey)
+}
+
+func newAsymmetricKey(id string, method jwt.SigningMethod, privateKey crypto.Signer, publicKey crypto.PublicKey) (SigningKey, error) {
+	if id == "" {
+		jwk, err := NewJWK(publicKey, method.Alg(), "")
+		if err != nil {
+			return SigningKey{}, err
+		}
+		id = jwk.Thumbprint()
+	}
+
+	key := SigningKey{
+		id:        id,
+		method:    method,
+		verifyKey: publicKey,
+		publicKey: publicKey,
+	}
+	if privateKey != nil {
+		key.signKey = privateKey
+	}
+
+	return key, nil
+}
+
+// Keyring holds the active signing key and the keys that are still accepted
+// for verification, so signing keys can be rotated without invalidating
+// tokens that were already issued.
+type Keyring struct {
+	active SigningKey
+	keys   map[string]SigningKey
+}
+
+func NewKeyring(active SigningKey, verificationKeys ...SigningKey) (*Keyring, error) {
+	if !active.CanSign() {
+		return nil, fmt.Errorf("active key %q cannot sign tokens", active.id)
+	}
+
+	keys := map[string]SigningKey{active.id: active}
+	for _, key := range verificationKeys {
+		if _, exists := keys[key.id]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", key.id)
+		}
+		keys[key.id] = key
+	}
+
+	return &Keyring{
+		active: active,
+		keys:   keys,
+	}, nil
+}
+
+// NewHMACKeyring builds a keyring from the active secret and a map of retired
+// secrets indexed by kid.
+func NewHMACKeyring(activeID string, activeSecret string, verificationSecrets map[string]string) (*Keyring, error) {
+	if activeID == "" {
+		activeID = DefaultKeyID
+	}
+
+	active, err := NewHMACKey(activeID, activeSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	verificationKeys := make([]SigningKey, 0, len(verificationSecrets))
+	for id, secret := range verificationSecrets {
+		key, err := NewHMACKey(id, secret)
+		if err != nil {
+			return nil, err
+		}
+		verificationKeys = append(verificationKeys, key)
+	}
+
+	return NewKeyring(active, verificationKeys...)
+}
+
+// NewAsymmetricKeyring builds a keyring from the active private key and a map
+// of retired key files indexed by kid.
+func NewAsymmetricKeyring(activeID string, algorithm string, privateKeyPath string, verificationKeyPaths map[string]string) (*Keyring, error) {
+	active, err := NewPrivateKey(activeID, algorithm, privateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	verificationKeys := make([]SigningKey, 0, len(verificationKeyPaths))
+	for id, path := range verificationKeyPaths {
+		key, err := NewVerificationKey(id, path)
+		if err != nil {
+			return nil, err
+		}
+		verificationKeys = append(verificationKeys, key)
+	}
+
+	return NewKeyring(active, verificationKeys...)
+}
+
+func (keyring *Keyring) Active() SigningKey {
+	return keyring.active
+}
+
+// Lookup returns the key for a kid. Every token is signed with a kid, so
+// tokens without one are rejected.
+func (keyring *Keyring) Lookup(id string) (SigningKey, bool) {
+	key, ok := keyring.keys[id]
+	return key, ok
+}
+
+// Sign signs the claims with the active key. typ is set as the JOSE header so
+// verifiers can tell token kinds apart before looking at the claims.
+func (keyring *Keyring) Sign(claims jwt.Claims, typ string) (string, error) {
+	jwtToken := jwt.NewWithClaims(keyring.active.method, claims)
+	jwtToken.Header["kid"] = keyring.active.id
+	jwtToken.Header["typ"] = typ
+	return jwtToken.SignedString(keyring.active.signKey)
+}
+
+// KeyFunc selects the verification key by kid and rejects any algorithm other
+// than the one the key was registered with.
+func (keyring *Keyring) KeyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := keyring.Lookup(kid)
+	if !ok || token.Method.Alg() != key.method.Alg() {
+		return nil, ErrInvalidToken
+	}
+
+	return key.verifyKey, nil
+}
+
+// JWKS publishes every asymmetric key of the keyring, including the
+// verification-only ones, so tokens signed before a rotation still verify.
+func (keyring *Keyring) JWKS() JWKS {
+	keySet := JWKS{Keys: []JWK{}}
+	for _, key := range keyring.keys {
+		if key.publicKey == nil {
+			continue
+		}
+
+		jwk, err := NewJWK(key.publicKey, key.method.Alg(), key.id)
+		if err != nil {
+			continue
+		}
+		keySet.Keys = append(keySet.Keys, jwk)
+	}
+	return keySet
+}
+
+// LoadPrivateKey reads a PEM encoded PKCS#1 or PKCS#8 RSA or Ed25519 private key.
+func LoadPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported private key type %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	switch signer := key.(type) {
+	case *rsa.PrivateKey:
+		if signer.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("invalid RSA key size: must be at least 2048 bits")
+		}
+		return signer, nil
+	case ed25519.PrivateKey:
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+}
+
+// LoadPublicKey reads a PEM encoded public key, or derives it from a private key.
+func LoadPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+		return key, nil
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+		return key, nil
+	default:
+		privateKey, err := LoadPrivateKey(path)
+		if err != nil {
+			return nil, err
+		}
+		return privateKey.Public(), nil
+	}
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM from %s", path)
+	}
+
+	return block, nil
+}
+
+func signingMethodForKey(algorithm string, publicKey crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		if algorithm != "" && algorithm != AlgorithmRS256 {
+			return nil, fmt.Errorf("algorithm %s does not match RSA key", algorithm)
+		}
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PublicKey:
+		if algorithm != "" && algorithm != AlgorithmEdDSA {
+			return nil, fmt.Errorf("algorithm %s does not match Ed25519 key", algorithm)
+		}
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", publicKey)
+	}
+}
@@ -0,0 +1,170 @@
+package token
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// A token signed before a rotation keeps verifying while its kid is listed
+// as a verification key, and is rejected once the kid is dropped.
+func TestKeyringRotation(t *testing.T) {
+	oldRSAPath := newRSAKeyPath(t)
+	oldEdPath := newEd25519KeyPath(t)
+
+	tests := []struct {
+		name   string
+		before func(t *testing.T) Maker
+		after  func(t *testing.T) Maker
+		// unknown is a maker whose keyring no longer holds the old kid.
+		unknown func(t *testing.T) Maker
+	}{
+		{
+			name: AlgorithmHS256,
+			before: func(t *testing.T) Maker {
+				return newTestHMACMaker(t, "2024-01", testAccessSecret, nil)
+			},
+			after: func(t *testing.T) Maker {
+				return newTestHMACMaker(t, "2024-02", "rotated-access-secret-long-enough-for-hs256", map[string]string{"2024-01": testAccessSecret})
+			},
+			unknown: func(t *testing.T) Maker {
+				return newTestHMACMaker(t, "2024-02", "rotated-access-secret-long-enough-for-hs256", nil)
+			},
+		},
+		{
+			name: AlgorithmRS256,
+			before: func(t *testing.T) Maker {
+				return newTestRotatedMaker(t, "2024-01", AlgorithmRS256, oldRSAPath, nil)
+			},
+			after: func(t *testing.T) Maker {
+				return newTestRotatedMaker(t, "2024-02", AlgorithmRS256, newRSAKeyPath(t), map[string]string{"2024-01": oldRSAPath})
+			},
+			unknown: func(t *testing.T) Maker {
+				return newTestRotatedMaker(t, "2024-02", AlgorithmRS256, newRSAKeyPath(t), nil)
+			},
+		},
+		{
+			name: AlgorithmEdDSA,
+			before: func(t *testing.T) Maker {
+				return newTestRotatedMaker(t, "2024-01", AlgorithmEdDSA, oldEdPath, nil)
+			},
+			after: func(t *testing.T) Maker {
+				return newTestRotatedMaker(t, "2024-02", AlgorithmEdDSA, newEd25519KeyPath(t), map[string]string{"2024-01": oldEdPath})
+			},
+			unknown: func(t *testing.T) Maker {
+				return newTestRotatedMaker(t, "2024-02", AlgorithmEdDSA, newEd25519KeyPath(t), nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, issued, err := tt.before(t).CreateToken(uuid.New(), "user@example.com", uuid.New(), time.Minute)
+			if err != nil {
+				t.Fatalf("CreateToken: %v", err)
+			}
+
+			rotated := tt.after(t)
+			payload, err := rotated.VerifyToken(token)
+			if err != nil {
+				t.Fatalf("token signed with the retired key was rejected: %v", err)
+			}
+			if payload.ID != issued.ID {
+				t.Fatalf("payload ID = %s, want %s", payload.ID, issued.ID)
+			}
+
+			if _, err := tt.unknown(t).VerifyToken(token); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("token with an unknown kid: err = %v, want %v", err, ErrInvalidToken)
+			}
+
+			// New tokens are signed with the new key only.
+			token, _, err = rotated.CreateToken(uuid.New(), "user@example.com", uuid.New(), time.Minute)
+			if err != nil {
+				t.Fatalf("CreateToken: %v", err)
+			}
+			if _, err := tt.before(t).VerifyToken(token); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("token signed with the new key verified by the old keyring: err = %v", err)
+			}
+		})
+	}
+}
+
+func TestKeyringPublishesRetiredKeys(t *testing.T) {
+	keys, err := NewAsymmetricKeyring("2024-02", AlgorithmEdDSA, newEd25519KeyPath(t), map[string]string{"2024-01": newEd25519KeyPath(t)})
+	if err != nil {
+		t.Fatalf("NewAsymmetricKeyring: %v", err)
+	}
+
+	kids := map[string]bool{}
+	for _, jwk := range keys.JWKS().Keys {
+		kids[jwk.Kid] = true
+	}
+	if len(kids) != 2 || !kids["2024-01"] || !kids["2024-02"] {
+		t.Fatalf("JWKS kids = %v, want 2024-01 and 2024-02", kids)
+	}
+}
+
+func TestKeyringLookup(t *testing.T) {
+	keys := newTestHMACKeyring(t, "2024-02", testAccessSecret, map[string]string{"2024-01": testRefreshSecret})
+
+	tests := []struct {
+		kid  string
+		want bool
+	}{
+		{kid: "2024-02", want: true},
+		{kid: "2024-01", want: true},
+		{kid: "2023-12", want: false},
+		{kid: "", want: false},
+	}
+
+	for _, tt := range tests {
+		if _, ok := keys.Lookup(tt.kid); ok != tt.want {
+			t.Errorf("Lookup(%q) = %v, want %v", tt.kid, ok, tt.want)
+		}
+	}
+}
+
+func TestNewKeyringRejectsDuplicateKid(t *testing.T) {
+	active, err := NewHMACKey("2024-01", testAccessSecret)
+	if err != nil {
+		t.Fatalf("NewHMACKey: %v", err)
+	}
+	retired, err := NewHMACKey("2024-01", testRefreshSecret)
+	if err != nil {
+		t.Fatalf("NewHMACKey: %v", err)
+	}
+
+	if _, err := NewKeyring(active, retired); err == nil {
+		t.Fatal("expected a duplicate kid to be rejected")
+	}
+}
+
+func newTestHMACMaker(t *testing.T, kid string, secret string, verificationSecrets map[string]string) Maker {
+	t.Helper()
+
+	maker, err := NewJWTMakerWithKeyrings(
+		newTestHMACKeyring(t, kid, secret, verificationSecrets),
+		newTestHMACKeyring(t, kid, testRefreshSecret, nil),
+	)
+	if err != nil {
+		t.Fatalf("NewJWTMakerWithKeyrings: %v", err)
+	}
+	return maker
+}
+
+func newTestRotatedMaker(t *testing.T, kid string, algorithm string, privateKeyPath string, verificationKeyPaths map[string]string) Maker {
+	t.Helper()
+
+	keys, err := NewAsymmetricKeyring(kid, algorithm, privateKeyPath, verificationKeyPaths)
+	if err != nil {
+		t.Fatalf("NewAsymmetricKeyring: %v", err)
+	}
+
+	maker, err := NewAsymmetricJWTMaker(keys, newTestHMACKeyring(t, kid, testRefreshSecret, nil))
+	if err != nil {
+		t.Fatalf("NewAsymmetricJWTMaker: %v", err)
+	}
+	return maker
+}
@@ -0,0 +1,173 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"aidanwoods.dev/go-paseto"
+	"github.com/google/uuid"
+
+	"cloud-sprint/internal/constants"
+)
+
+const (
+	FormatJWT          = "jwt"
+	FormatPasetoLocal  = "paseto-local"
+	FormatPasetoPublic = "paseto-public"
+)
+
+// PasetoMaker issues PASETO v4 tokens, either encrypted (v4.local) or signed
+// with Ed25519 (v4.public). The token type is bound as implicit assertion so
+// an access token can never be accepted as a refresh token and vice versa.
+type PasetoMaker struct {
+	purpose         paseto.Purpose
+	accessLocalKey  paseto.V4SymmetricKey
+	refreshLocalKey paseto.V4SymmetricKey
+	secretKey       paseto.V4AsymmetricSecretKey
+	publicKey       paseto.V4AsymmetricPublicKey
+}
+
+// NewPasetoLocalMaker takes hex encoded 32 byte keys for access and refresh tokens.
+func NewPasetoLocalMaker(accessKeyHex, refreshKeyHex string) (Maker, error) {
+	accessKey, err := paseto.V4SymmetricKeyFromHex(accessKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid access token key: must be 32 hex encoded bytes")
+	}
+
+	refreshKey, err := paseto.V4SymmetricKeyFromHex(refreshKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid refresh token key: must be 32 hex encoded bytes")
+	}
+
+	return &PasetoMaker{
+		purpose:         paseto.Local,
+		accessLocalKey:  accessKey,
+		refreshLocalKey: refreshKey,
+	}, nil
+}
+
+// NewPasetoPublicMaker takes the path of a PEM encoded Ed25519 private key.
+func NewPasetoPublicMaker(privateKeyPath string) (Maker, error) {
+	privateKey, err := LoadPrivateKey(privateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	ed25519Key, ok := privateKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("PASETO v4.public requires an Ed25519 key")
+	}
+
+	secretKey, err := paseto.NewV4AsymmetricSecretKeyFromEd25519(ed25519Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Ed25519 key: %w", err)
+	}
+
+	return &PasetoMaker{
+		purpose:   paseto.Public,
+		secretKey: secretKey,
+		publicKey: secretKey.Public(),
+	}, nil
+}
+
+func (maker *PasetoMaker) CreateToken(userID uuid.UUID, email string, sessionID uuid.UUID, duration time.Duration) (string, *Payload, error) {
+	return maker.createToken(userID, email, sessionID, duration, constants.AccessTokenType)
+}
+
+func (maker *PasetoMaker) VerifyToken(token string) (*Payload, error) {
+	return maker.verifyToken(token, constants.AccessTokenType)
+}
+
+func (maker *PasetoMaker) CreateRefreshToken(userID uuid.UUID, email string, sessionID uuid.UUID, duration time.Duration) (string, *Payload, error) {
+	return maker.createToken(userID, email, sessionID, duration, constants.RefreshTokenType)
+}
+
+func (maker *PasetoMaker) VerifyRefreshToken(refreshToken string) (*Payload, error) {
+	return maker.verifyToken(refreshToken, constants.RefreshTokenType)
+}
+
+func (maker *PasetoMaker) CreateMFAToken(userID uuid.UUID, email string, duration time.Duration) (string, *Payload, error) {
+	return maker.createToken(userID, email, uuid.Nil, duration, constants.MFATokenType)
+}
+
+func (maker *PasetoMaker) VerifyMFAToken(mfaToken string) (*Payload, error) {
+	return maker.verifyToken(mfaToken, constants.MFATokenType)
+}
+
+func (maker *PasetoMaker) CreateMagicLinkToken(userID uuid.UUID, email string, duration time.Duration) (string, *Payload, error) {
+	return maker.createToken(userID, email, uuid.Nil, duration, constants.MagicLinkTokenType)
+}
+
+func (maker *PasetoMaker) VerifyMagicLinkToken(magicLinkToken string) (*Payload, error) {
+	return maker.verifyToken(magicLinkToken, constants.MagicLinkTokenType)
+}
+
+func (maker *PasetoMaker) createToken(userID uuid.UUID, email string, sessionID uuid.UUID, duration time.Duration, tokenType constants.TokenType) (string, *Payload, error) {
+	payload, err := NewPayload(userID, email, sessionID, duration, tokenType)
+	if err != nil {
+		return "", nil, err
+	}
+
+	claims, err := json.Marshal(payload)
+	if err != nil {
+		return "", nil, err
+	}
+
+	pasetoToken, err := paseto.NewTokenFromClaimsJSON(claims, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	pasetoToken.SetJti(payload.ID)
+	pasetoToken.SetSubject(payload.UserID)
+	pasetoToken.SetAudience(Audience(tokenType))
+	pasetoToken.SetIssuedAt(payload.IssuedAt)
+	pasetoToken.SetNotBefore(payload.IssuedAt)
+	pasetoToken.SetExpiration(payload.ExpiredAt)
+
+	implicit := []byte(tokenType)
+	if maker.purpose == paseto.Public {
+		return pasetoToken.V4Sign(maker.secretKey, implicit), payload, nil
+	}
+
+	return pasetoToken.V4Encrypt(maker.localKey(tokenType), implicit), payload, nil
+}
+
+func (maker *PasetoMaker) verifyToken(token string, tokenType constants.TokenType) (*Payload, error) {
+	parser := paseto.NewParserWithoutExpiryCheck()
+	implicit := []byte(tokenType)
+
+	var pasetoToken *paseto.Token
+	var err error
+	if maker.purpose == paseto.Public {
+		pasetoToken, err = parser.ParseV4Public(maker.publicKey, token, implicit)
+	} else {
+		pasetoToken, err = parser.ParseV4Local(maker.localKey(tokenType), token, implicit)
+	}
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	payload := &Payload{}
+	if err := json.Unmarshal(pasetoToken.ClaimsJSON(), payload); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if payload.TokenType != tokenType {
+		return nil, ErrInvalidToken
+	}
+
+	if err := payload.Valid(); err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+func (maker *PasetoMaker) localKey(tokenType constants.TokenType) paseto.V4SymmetricKey {
+	if tokenType == constants.RefreshTokenType {
+		return maker.refreshLocalKey
+	}
+	return maker.accessLocalKey
+}
@@ -0,0 +1,105 @@
+package token
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	testPasetoAccessKey  = "707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f"
+	testPasetoRefreshKey = "909192939495969798999a9b9c9d9e9fa0a1a2a3a4a5a6a7a8a9aaabacadaeaf"
+)
+
+func newTestPasetoMakers(t *testing.T) map[string]Maker {
+	t.Helper()
+
+	localMaker, err := NewPasetoLocalMaker(testPasetoAccessKey, testPasetoRefreshKey)
+	if err != nil {
+		t.Fatalf("NewPasetoLocalMaker: %v", err)
+	}
+
+	publicMaker, err := NewPasetoPublicMaker(newEd25519KeyPath(t))
+	if err != nil {
+		t.Fatalf("NewPasetoPublicMaker: %v", err)
+	}
+
+	return map[string]Maker{
+		FormatPasetoLocal:  localMaker,
+		FormatPasetoPublic: publicMaker,
+	}
+}
+
+func TestPasetoMakerRoundTrip(t *testing.T) {
+	for name, maker := range newTestPasetoMakers(t) {
+		t.Run(name, func(t *testing.T) {
+			testRoundTrip(t, maker)
+		})
+	}
+}
+
+func TestPasetoMakerExpiredToken(t *testing.T) {
+	for name, maker := range newTestPasetoMakers(t) {
+		t.Run(name, func(t *testing.T) {
+			testExpired(t, maker)
+		})
+	}
+}
+
+func TestPasetoMakerTamperedToken(t *testing.T) {
+	for name, maker := range newTestPasetoMakers(t) {
+		t.Run(name, func(t *testing.T) {
+			token, _, err := maker.CreateToken(uuid.New(), "user@example.com", uuid.New(), time.Minute)
+			if err != nil {
+				t.Fatalf("CreateToken: %v", err)
+			}
+
+			// Flip a character in the middle of the body, well away from the
+			// padding bits of the last base64 character.
+			i := strings.LastIndex(token, ".") + (len(token)-strings.LastIndex(token, "."))/2
+			replacement := "A"
+			if token[i] == 'A' {
+				replacement = "B"
+			}
+			tampered := token[:i] + replacement + token[i+1:]
+
+			if _, err := maker.VerifyToken(tampered); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("VerifyToken error = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestPasetoMakerRejectsOtherKey(t *testing.T) {
+	other, err := NewPasetoPublicMaker(newEd25519KeyPath(t))
+	if err != nil {
+		t.Fatalf("NewPasetoPublicMaker: %v", err)
+	}
+
+	token, _, err := other.CreateToken(uuid.New(), "user@example.com", uuid.New(), time.Minute)
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+
+	if _, err := newTestPasetoMakers(t)[FormatPasetoPublic].VerifyToken(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("VerifyToken error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestNewPasetoLocalMakerRejectsShortKeys(t *testing.T) {
+	if _, err := NewPasetoLocalMaker("abcd", testPasetoRefreshKey); err == nil {
+		t.Fatal("expected a short access key to be rejected")
+	}
+	if _, err := NewPasetoLocalMaker(testPasetoAccessKey, "abcd"); err == nil {
+		t.Fatal("expected a short refresh key to be rejected")
+	}
+}
+
+func TestNewPasetoPublicMakerRequiresEd25519(t *testing.T) {
+	if _, err := NewPasetoPublicMaker(newRSAKeyPath(t)); err == nil {
+		t.Fatal("expected an RSA key to be rejected")
+	}
+}
@@ -1,6 +1,10 @@
 package util
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
 
 type SetCookieData struct {
 	Name      string
@@ -22,3 +26,18 @@ func SetHttpOnlyCookie(c *fiber.Ctx, data SetCookieData) {
 
 	c.Cookie(cookie)
 }
+
+func ClearHttpOnlyCookie(c *fiber.Ctx, name string, env string) {
+	cookie := &fiber.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+		HTTPOnly: true,
+		Secure:   env == "production",
+		SameSite: "Strict",
+	}
+
+	c.Cookie(cookie)
+}
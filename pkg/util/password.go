@@ -1,11 +1,17 @@
 package util
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+var ErrPasswordMismatch = errors.New("password does not match")
+
+// HashPassword hashes with bcrypt at the default cost. Account passwords
+// should go through a PasswordHasher built from the config instead.
 func HashPassword(password string) (string, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -14,6 +20,11 @@ func HashPassword(password string) (string, error) {
 	return string(hashedPassword), nil
 }
 
+// CheckPassword compares a password against an argon2id or bcrypt hash. The
+// algorithm and its parameters are read from the encoded hash.
 func CheckPassword(password string, hashedPassword string) error {
+	if strings.HasPrefix(hashedPassword, argon2idPrefix) {
+		return checkArgon2idPassword(password, hashedPassword)
+	}
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
@@ -0,0 +1,154 @@
+package util
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+const (
+	PasswordTooShort             = "too_short"
+	PasswordMissingUppercase     = "missing_uppercase"
+	PasswordMissingLowercase     = "missing_lowercase"
+	PasswordMissingDigit         = "missing_digit"
+	PasswordMissingSymbol        = "missing_symbol"
+	PasswordContainsPersonalInfo = "contains_personal_info"
+	PasswordBreached             = "breached"
+)
+
+// minPersonalInfoLength keeps short names from rejecting unrelated passwords.
+const minPersonalInfoLength = 3
+
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicy describes the requirements for new passwords.
+//
+// BreachListDir points to a local copy of a k-anonymity breached password
+// list: one file per five character SHA-1 prefix, named PREFIX.txt, holding
+// "SUFFIX:COUNT" lines as served by the Pwned Passwords range API. Leave it
+// empty to skip the breach check.
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	BreachListDir string
+}
+
+// Validate returns every rule the password breaks. personalInfo holds values
+// such as the email and names that must not appear inside the password. An
+// error is only returned when the breach list cannot be read.
+func (p PasswordPolicy) Validate(password string, personalInfo ...string) ([]PasswordViolation, error) {
+	violations := []PasswordViolation{}
+
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordTooShort,
+			Message: fmt.Sprintf("password must be at least %d characters long", p.MinLength),
+		})
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireUpper && !hasUpper {
+		violations = append(violations, PasswordViolation{Code: PasswordMissingUppercase, Message: "password must contain an uppercase letter"})
+	}
+	if p.RequireLower && !hasLower {
+		violations = append(violations, PasswordViolation{Code: PasswordMissingLowercase, Message: "password must contain a lowercase letter"})
+	}
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, PasswordViolation{Code: PasswordMissingDigit, Message: "password must contain a digit"})
+	}
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, PasswordViolation{Code: PasswordMissingSymbol, Message: "password must contain a symbol"})
+	}
+
+	if containsPersonalInfo(password, personalInfo) {
+		violations = append(violations, PasswordViolation{Code: PasswordContainsPersonalInfo, Message: "password must not contain your email or name"})
+	}
+
+	if p.BreachListDir != "" {
+		breached, err := isBreachedPassword(p.BreachListDir, password)
+		if err != nil {
+			return violations, err
+		}
+		if breached {
+			violations = append(violations, PasswordViolation{Code: PasswordBreached, Message: "password has appeared in a data breach, please choose another one"})
+		}
+	}
+
+	return violations, nil
+}
+
+func containsPersonalInfo(password string, personalInfo []string) bool {
+	lowered := strings.ToLower(password)
+
+	for _, value := range personalInfo {
+		value = strings.ToLower(strings.TrimSpace(value))
+
+		candidates := []string{value}
+		if localPart, _, found := strings.Cut(value, "@"); found {
+			candidates = append(candidates, localPart)
+		}
+
+		for _, candidate := range candidates {
+			if len(candidate) >= minPersonalInfoLength && strings.Contains(lowered, candidate) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isBreachedPassword looks the password up by its SHA-1 prefix so only the
+// matching range file is read.
+func isBreachedPassword(dir string, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := digest[:5], digest[5:]
+
+	file, err := os.Open(filepath.Join(dir, prefix+".txt"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to open breach list: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		hashSuffix, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(hashSuffix, suffix) {
+			return true, nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to read breach list: %w", err)
+	}
+
+	return false, nil
+}
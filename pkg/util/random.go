@@ -9,6 +9,8 @@ import (
 
 const alphabet = "abcdefghijklmnopqrstuvwxyz"
 
+// The helpers below produce fixture data and are not safe for secrets; use
+// the generators in secret.go for OTPs, tokens and passwords.
 var rng = rand.New(rand.NewSource(time.Now().UnixNano()))
 
 func RandomInt(min, max int64) int64 {
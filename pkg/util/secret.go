@@ -0,0 +1,59 @@
+package util
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const digits = "0123456789"
+
+// GenerateSecretString returns length characters drawn uniformly from
+// alphabet using crypto/rand. Use it for anything that acts as a credential.
+func GenerateSecretString(alphabet string, length int) (string, error) {
+	if alphabet == "" || length <= 0 {
+		return "", errors.New("alphabet and length must not be empty")
+	}
+
+	max := big.NewInt(int64(len(alphabet)))
+
+	var sb strings.Builder
+	sb.Grow(length)
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate secret: %w", err)
+		}
+		sb.WriteByte(alphabet[n.Int64()])
+	}
+
+	return sb.String(), nil
+}
+
+// GenerateOTP returns a numeric one-time password. Leading zeros are kept so
+// every code has exactly length digits.
+func GenerateOTP(length int) (string, error) {
+	return GenerateSecretString(digits, length)
+}
+
+// GenerateToken returns a URL-safe token carrying numBytes bytes of entropy.
+func GenerateToken(numBytes int) (string, error) {
+	if numBytes <= 0 {
+		return "", errors.New("token size must be positive")
+	}
+
+	b := make([]byte, numBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// GenerateState returns a value for the OAuth state parameter.
+func GenerateState() (string, error) {
+	return GenerateToken(32)
+}
@@ -0,0 +1,162 @@
+package util
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// Chi-square critical values at p = 0.0001, so a correct generator fails a
+// run about once in ten thousand.
+const (
+	chiSquare9DoF  = 33.72
+	chiSquare15DoF = 44.26
+	chiSquare63DoF = 113.5
+)
+
+const urlSafeAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+func chiSquare(counts []int) float64 {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+
+	expected := float64(total) / float64(len(counts))
+	var sum float64
+	for _, count := range counts {
+		diff := float64(count) - expected
+		sum += diff * diff / expected
+	}
+	return sum
+}
+
+func TestGenerateOTPLength(t *testing.T) {
+	for _, length := range []int{1, 6, 8, 12} {
+		otp, err := GenerateOTP(length)
+		if err != nil {
+			t.Fatalf("GenerateOTP(%d): %v", length, err)
+		}
+		if len(otp) != length {
+			t.Fatalf("GenerateOTP(%d) returned %d digits", length, len(otp))
+		}
+		if strings.Trim(otp, digits) != "" {
+			t.Fatalf("GenerateOTP(%d) returned non-digits: %q", length, otp)
+		}
+	}
+}
+
+func TestGenerateOTPDigitDistribution(t *testing.T) {
+	counts := make([]int, len(digits))
+	for i := 0; i < 20000; i++ {
+		otp, err := GenerateOTP(6)
+		if err != nil {
+			t.Fatalf("GenerateOTP: %v", err)
+		}
+		for _, digit := range otp {
+			counts[digit-'0']++
+		}
+	}
+
+	if stat := chiSquare(counts); stat > chiSquare9DoF {
+		t.Fatalf("digits are not uniform: chi-square %.2f > %.2f, counts %v", stat, chiSquare9DoF, counts)
+	}
+}
+
+func TestGenerateSecretStringDistribution(t *testing.T) {
+	counts := make([]int, len(urlSafeAlphabet))
+	for i := 0; i < 2000; i++ {
+		secret, err := GenerateSecretString(urlSafeAlphabet, 32)
+		if err != nil {
+			t.Fatalf("GenerateSecretString: %v", err)
+		}
+		for _, char := range secret {
+			counts[strings.IndexRune(urlSafeAlphabet, char)]++
+		}
+	}
+
+	if stat := chiSquare(counts); stat > chiSquare63DoF {
+		t.Fatalf("characters are not uniform: chi-square %.2f > %.2f", stat, chiSquare63DoF)
+	}
+}
+
+func TestGenerateSecretStringRejectsEmptyInput(t *testing.T) {
+	if _, err := GenerateSecretString("", 6); err == nil {
+		t.Fatal("expected an error for an empty alphabet")
+	}
+	if _, err := GenerateSecretString(digits, 0); err == nil {
+		t.Fatal("expected an error for a zero length")
+	}
+	if _, err := GenerateOTP(-1); err == nil {
+		t.Fatal("expected an error for a negative length")
+	}
+}
+
+func TestGenerateTokenLength(t *testing.T) {
+	for _, numBytes := range []int{1, 16, 32, 48} {
+		token, err := GenerateToken(numBytes)
+		if err != nil {
+			t.Fatalf("GenerateToken(%d): %v", numBytes, err)
+		}
+		if len(token) != base64.RawURLEncoding.EncodedLen(numBytes) {
+			t.Fatalf("GenerateToken(%d) returned %d characters", numBytes, len(token))
+		}
+		if strings.Trim(token, urlSafeAlphabet) != "" {
+			t.Fatalf("GenerateToken(%d) is not URL-safe: %q", numBytes, token)
+		}
+
+		decoded, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			t.Fatalf("GenerateToken(%d) does not decode: %v", numBytes, err)
+		}
+		if len(decoded) != numBytes {
+			t.Fatalf("GenerateToken(%d) carries %d bytes", numBytes, len(decoded))
+		}
+	}
+
+	if _, err := GenerateToken(0); err == nil {
+		t.Fatal("expected an error for a zero size")
+	}
+}
+
+// The encoded characters are not uniform since the last one only carries the
+// leftover bits, so the decoded bytes are bucketed by nibble instead.
+func TestGenerateTokenByteDistribution(t *testing.T) {
+	counts := make([]int, 16)
+	for i := 0; i < 2000; i++ {
+		token, err := GenerateToken(32)
+		if err != nil {
+			t.Fatalf("GenerateToken: %v", err)
+		}
+
+		decoded, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			t.Fatalf("GenerateToken does not decode: %v", err)
+		}
+		for _, b := range decoded {
+			counts[b>>4]++
+			counts[b&0x0f]++
+		}
+	}
+
+	if stat := chiSquare(counts); stat > chiSquare15DoF {
+		t.Fatalf("bytes are not uniform: chi-square %.2f > %.2f, counts %v", stat, chiSquare15DoF, counts)
+	}
+}
+
+func TestGenerateState(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		state, err := GenerateState()
+		if err != nil {
+			t.Fatalf("GenerateState: %v", err)
+		}
+		if len(state) != base64.RawURLEncoding.EncodedLen(32) {
+			t.Fatalf("GenerateState returned %d characters", len(state))
+		}
+		if seen[state] {
+			t.Fatalf("GenerateState repeated %q", state)
+		}
+		seen[state] = true
+	}
+}
@@ -0,0 +1,20 @@
+package util
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+)
+
+// HashToken returns the hex encoded SHA-256 digest of a one-time credential
+// (OTP, reset token, refresh token) so only the digest is stored at rest.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckTokenHash compares a presented credential against a stored digest in
+// constant time.
+func CheckTokenHash(token string, hashedToken string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashToken(token)), []byte(hashedToken)) == 1
+}